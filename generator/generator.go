@@ -0,0 +1,494 @@
+// Package generator turns ROS packages (.msg and .srv files) into Go structs that are compiled
+// into the module, along with their registry entries and tests.
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgproc"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/messages"
+)
+
+const Command = "generate"
+
+// PackagesEnv lists the ROS package directories generate uses when none is given
+const PackagesEnv = "ROS_MESSAGE_PACKAGES"
+
+const goroslibMsgs = "github.com/bluenviron/goroslib/v2/pkg/msgs/"
+
+var ErrNoPackages = errors.New("at least one ROS package directory is required, as an argument or in $" + PackagesEnv)
+
+// Packages shipped by goroslib that generated code can import instead of generating
+var goroslibPackages = map[string]bool{
+	"ackermann_msgs": true, "actionlib": true, "actionlib_msgs": true, "audio_common_msgs": true,
+	"control_msgs": true, "diagnostic_msgs": true, "geographic_msgs": true, "geometry_msgs": true,
+	"mavros_msgs": true, "nav_msgs": true, "rosgraph_msgs": true, "sensor_msgs": true,
+	"shape_msgs": true, "sound_play": true, "std_msgs": true, "std_srvs": true, "stereo_msgs": true,
+	"tf": true, "tf2_msgs": true, "trajectory_msgs": true, "uuid_msgs": true, "velodyne_msgs": true,
+	"vision_msgs": true, "visualization_msgs": true,
+}
+
+var goPrimitiveTypes = map[string]string{
+	"bool":     "bool",
+	"int8":     "int8",
+	"uint8":    "uint8",
+	"int16":    "int16",
+	"uint16":   "uint16",
+	"int32":    "int32",
+	"uint32":   "uint32",
+	"int64":    "int64",
+	"uint64":   "uint64",
+	"float32":  "float32",
+	"float64":  "float64",
+	"string":   "string",
+	"time":     "time.Time",
+	"duration": "time.Duration",
+	"byte":     "int8",
+	"char":     "uint8",
+}
+
+// Run implements the generate subcommand of the module binary:
+//
+//	viam-ros-sensor-bridge generate [-out messages] <ros_package_dir>...
+//
+// Without package directories, the ones listed in $ROS_MESSAGE_PACKAGES are used, separated like
+// $PATH. That is what the go:generate directive of the messages package relies on.
+func Run(args []string) error {
+	flags := flag.NewFlagSet(Command, flag.ContinueOnError)
+	out := flags.String("out", "messages", "directory of the messages package to write the generated files to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	rosDirs := flags.Args()
+	if len(rosDirs) == 0 {
+		rosDirs = filepath.SplitList(os.Getenv(PackagesEnv))
+	}
+	return Generate(rosDirs, *out)
+}
+
+// Generate writes three files to outDir for every ROS package found in rosDirs:
+// <pkg>/<pkg>.go with the structs, <pkg>_generated.go with the registry entries and
+// <pkg>_generated_test.go with a round-trip test for each type.
+func Generate(rosDirs []string, outDir string) error {
+	if len(rosDirs) == 0 {
+		return ErrNoPackages
+	}
+
+	defs, err := messages.ParseMessageDefinitions(rosDirs)
+	if err != nil {
+		return err
+	}
+	// Build the types at runtime as well, to get the MD5 sums the generated tests check against
+	types, err := messages.BuildMessageTypes(defs)
+	if err != nil {
+		return err
+	}
+
+	importBase, err := goImportPath(outDir)
+	if err != nil {
+		return err
+	}
+
+	g := &generator{
+		importBase: importBase,
+		packages:   map[string][]*messages.MessageDefinition{},
+		services:   map[string][]string{},
+	}
+	for _, def := range defs {
+		g.packages[def.Package] = append(g.packages[def.Package], def)
+	}
+	for _, dir := range rosDirs {
+		if err := g.findServices(dir); err != nil {
+			return err
+		}
+	}
+
+	for pkg, pkgDefs := range g.packages {
+		sort.Slice(pkgDefs, func(i, j int) bool { return pkgDefs[i].Name < pkgDefs[j].Name })
+
+		structs, err := g.structsFile(pkg, pkgDefs)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(outDir, pkg, pkg+".go"), structs); err != nil {
+			return err
+		}
+
+		reg, err := g.registryFile(pkg, pkgDefs)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(outDir, pkg+"_generated.go"), reg); err != nil {
+			return err
+		}
+
+		test, err := g.testFile(pkg, pkgDefs, types)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(outDir, pkg+"_generated_test.go"), test); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type generator struct {
+	importBase string
+	packages   map[string][]*messages.MessageDefinition
+	// services holds the .srv names per package, their request and response are in packages
+	services map[string][]string
+}
+
+func (g *generator) findServices(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".srv" {
+			return nil
+		}
+		pkgDir := filepath.Dir(path)
+		if filepath.Base(pkgDir) == "srv" {
+			pkgDir = filepath.Dir(pkgDir)
+		}
+		pkg := filepath.Base(pkgDir)
+		g.services[pkg] = append(g.services[pkg], strings.TrimSuffix(filepath.Base(path), ".srv"))
+		return nil
+	})
+}
+
+type structField struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+type structConst struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+type structDef struct {
+	Name        string
+	Package     string
+	Definitions string
+	Constants   []structConst
+	Fields      []structField
+}
+
+var structsTemplate = template.Must(template.New("structs").Parse(`// Code generated by viam-ros-sensor-bridge generate. DO NOT EDIT.
+
+// Package {{ .Package }} contains the {{ .Package }} ROS messages.
+package {{ .Package }}
+
+import (
+{{ .Imports }}
+)
+{{ range .Structs }}
+{{- if .Constants }}
+const (
+{{- $name := .Name }}
+{{- range .Constants }}
+	{{ $name }}_{{ .Name }} {{ .Type }} = {{ .Value }}
+{{- end }}
+)
+{{ end }}
+type {{ .Name }} struct {
+	msg.Package ` + "`" + `ros:"{{ .Package }}"` + "`" + `
+{{- if .Definitions }}
+	msg.Definitions ` + "`" + `ros:"{{ .Definitions }}"` + "`" + `
+{{- end }}
+{{- range .Fields }}
+	{{ .Name }} {{ .Type }}{{ if .Tag }} ` + "`" + `{{ .Tag }}` + "`" + `{{ end }}
+{{- end }}
+}
+{{ end }}
+{{- range .Services }}
+type {{ . }} struct {
+	msg.Package ` + "`" + `ros:"{{ $.Package }}"` + "`" + `
+	{{ . }}Request
+	{{ . }}Response
+}
+{{ end -}}
+`))
+
+func (g *generator) structsFile(pkg string, defs []*messages.MessageDefinition) ([]byte, error) {
+	imports := map[string]bool{"github.com/bluenviron/goroslib/v2/pkg/msg": true}
+	stdImports := map[string]bool{}
+	var structs []structDef
+	for _, def := range defs {
+		s := structDef{Name: def.Name, Package: pkg, Definitions: strings.Join(def.Constants, ",")}
+		for _, c := range def.Constants {
+			sc, err := parseConstant(c)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", def.TypeName(), err)
+			}
+			s.Constants = append(s.Constants, sc)
+		}
+		for _, f := range def.Fields {
+			sf, err := g.field(pkg, f, imports, stdImports)
+			if err != nil {
+				return nil, fmt.Errorf("%v.%v: %w", def.TypeName(), f.Name, err)
+			}
+			s.Fields = append(s.Fields, sf)
+		}
+		structs = append(structs, s)
+	}
+
+	services := g.services[pkg]
+	sort.Strings(services)
+	return render(structsTemplate, map[string]interface{}{
+		"Package":  pkg,
+		"Imports":  importBlock(stdImports, imports),
+		"Structs":  structs,
+		"Services": services,
+	})
+}
+
+func (g *generator) field(pkg string, f messages.MessageField, imports map[string]bool, stdImports map[string]bool) (structField, error) {
+	ft, err := messages.ParseFieldType(pkg, f.Type)
+	if err != nil {
+		return structField{}, err
+	}
+
+	sf := structField{Name: messages.GoFieldName(f.Name)}
+	// the msg.Package and msg.Definitions markers already use these names
+	if sf.Name == "Package" || sf.Name == "Definitions" {
+		sf.Name += "_"
+	}
+	var tags []string
+	if camelToSnake(sf.Name) != f.Name {
+		tags = append(tags, fmt.Sprintf(`rosname:"%v"`, f.Name))
+	}
+
+	if goType, ok := goPrimitiveTypes[ft.Elem]; ok {
+		sf.Type = goType
+		if strings.HasPrefix(goType, "time.") {
+			stdImports["time"] = true
+		}
+		if ft.Elem == "byte" || ft.Elem == "char" {
+			tags = append(tags, fmt.Sprintf(`rostype:"%v"`, ft.Elem))
+		}
+	} else {
+		parts := strings.SplitN(ft.Elem, "/", 2)
+		switch {
+		case parts[0] == pkg:
+			sf.Type = parts[1]
+		case g.packages[parts[0]] != nil:
+			imports[g.importBase+"/"+parts[0]] = true
+			sf.Type = parts[0] + "." + parts[1]
+		case goroslibPackages[parts[0]]:
+			imports[goroslibMsgs+parts[0]] = true
+			sf.Type = parts[0] + "." + parts[1]
+		default:
+			return sf, fmt.Errorf("%w: %v", messages.ErrTypeNotFound, ft.Elem)
+		}
+	}
+
+	if ft.IsArray {
+		if ft.Len > 0 {
+			sf.Type = "[" + strconv.Itoa(ft.Len) + "]" + sf.Type
+		} else {
+			sf.Type = "[]" + sf.Type
+		}
+	}
+	sf.Tag = strings.Join(tags, " ")
+	return sf, nil
+}
+
+func parseConstant(c string) (structConst, error) {
+	typ, rest, _ := strings.Cut(c, " ")
+	name, value, _ := strings.Cut(rest, "=")
+	sc := structConst{Name: name, Type: goPrimitiveTypes[typ], Value: value}
+	switch typ {
+	case "string":
+		sc.Value = strconv.Quote(value)
+	case "bool":
+		b, err := strconv.ParseBool(strings.ToLower(value))
+		if err != nil {
+			return sc, fmt.Errorf("%w: invalid bool constant %v", messages.ErrInvalidDefinition, c)
+		}
+		sc.Value = strconv.FormatBool(b)
+	case "time", "duration", "":
+		return sc, fmt.Errorf("%w: unsupported constant %v", messages.ErrInvalidDefinition, c)
+	}
+	return sc, nil
+}
+
+var registryTemplate = template.Must(template.New("registry").Parse(`// Code generated by viam-ros-sensor-bridge generate. DO NOT EDIT.
+
+package messages
+
+import (
+	"{{ .Import }}"
+)
+
+var {{ .Package }}_generated_registry = Registrations{
+{{- range .Types }}
+	"{{ $.Package }}/{{ . }}": {{ $.Package }}.{{ . }}{},
+{{- end }}
+}
+
+func init() {
+	registry.RegisterAll({{ .Package }}_generated_registry)
+}
+`))
+
+func (g *generator) registryFile(pkg string, defs []*messages.MessageDefinition) ([]byte, error) {
+	return render(registryTemplate, map[string]interface{}{
+		"Package": pkg,
+		"Import":  g.importBase + "/" + pkg,
+		"Types":   typeNames(defs),
+	})
+}
+
+var testTemplate = template.Must(template.New("test").Parse(`// Code generated by viam-ros-sensor-bridge generate. DO NOT EDIT.
+
+package messages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+{{ range .Types }}
+func TestGenerated{{ .Test }}RoundTrip(t *testing.T) {
+	sum, e := registry.MD5("{{ .Type }}")
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, "{{ .MD5 }}", sum, "MD5 should match the .msg definition")
+	m, e := sampleMessage("{{ .Type }}")
+	assert.Nil(t, e, "Error should be nil")
+	r, e := convertFromRosMsg(m)
+	assert.Nil(t, e, "Error should be nil")
+	back, e := ConvertToRosMsg("{{ .Type }}", r)
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, m, back, "Message should survive a round trip")
+}
+{{ end -}}
+`))
+
+type testDef struct {
+	Test string
+	Type string
+	MD5  string
+}
+
+func (g *generator) testFile(pkg string, defs []*messages.MessageDefinition, types map[string]reflect.Type) ([]byte, error) {
+	var tests []testDef
+	for _, def := range defs {
+		sum, err := msgproc.MD5(reflect.New(types[def.TypeName()]).Elem().Interface())
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, testDef{
+			Test: messages.GoFieldName(pkg) + def.Name,
+			Type: def.TypeName(),
+			MD5:  sum,
+		})
+	}
+	return render(testTemplate, map[string]interface{}{"Types": tests})
+}
+
+func render(t *template.Template, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
+
+// goImportPath returns the import path of dir by looking for the go.mod of its module.
+func goImportPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for root := abs; ; root = filepath.Dir(root) {
+		b, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(b), "\n") {
+				if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+					rel, err := filepath.Rel(root, abs)
+					if err != nil {
+						return "", err
+					}
+					return strings.TrimSuffix(strings.TrimSpace(module)+"/"+filepath.ToSlash(rel), "/."), nil
+				}
+			}
+			return "", fmt.Errorf("no module in %v", filepath.Join(root, "go.mod"))
+		}
+		if filepath.Dir(root) == root {
+			return "", fmt.Errorf("%v is not inside a go module", dir)
+		}
+	}
+}
+
+func typeNames(defs []*messages.MessageDefinition) []string {
+	names := make([]string, 0, len(defs))
+	for _, def := range defs {
+		names = append(names, def.Name)
+	}
+	return names
+}
+
+// importBlock lists the standard library imports first, in their own group
+func importBlock(stdImports map[string]bool, imports map[string]bool) string {
+	var groups []string
+	for _, m := range []map[string]bool{stdImports, imports} {
+		if len(m) == 0 {
+			continue
+		}
+		var lines []string
+		for _, k := range sortedKeys(m) {
+			lines = append(lines, strconv.Quote(k))
+		}
+		groups = append(groups, strings.Join(lines, "\n"))
+	}
+	return strings.Join(groups, "\n\n")
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// camelToSnake mirrors goroslib, a rosname tag is only needed when it doesn't round trip
+func camelToSnake(in string) string {
+	var sb strings.Builder
+	for i, r := range in {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				sb.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/messages"
+)
+
+func writeTestFile(t *testing.T, path string, content string) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func setup(t *testing.T) (string, string) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "go.mod"), "module example.com/bridge\n\ngo 1.21\n")
+	out := filepath.Join(root, "messages")
+	assert.Nil(t, os.MkdirAll(out, 0o755))
+
+	rosDir := filepath.Join(t.TempDir(), "robot_msgs")
+	writeTestFile(t, filepath.Join(rosDir, "msg", "Cell.msg"), "float32 voltage\nint64 cell_id\nstring package\n")
+	writeTestFile(t, filepath.Join(rosDir, "msg", "Pack.msg"), `uint8 OK=0
+string LABEL=main # not a comment
+Header header
+Cell[] cells
+byte[4] raw
+time seen
+geometry_msgs/Vector3 accel
+`)
+	writeTestFile(t, filepath.Join(rosDir, "srv", "Reset.srv"), "bool hard\n---\nbool success\n")
+	return rosDir, out
+}
+
+func read(t *testing.T, path string) string {
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err, "Error should be nil")
+	return string(b)
+}
+
+func TestGenerate(t *testing.T) {
+	rosDir, out := setup(t)
+	assert.Nil(t, Generate([]string{rosDir}, out))
+
+	structs := read(t, filepath.Join(out, "robot_msgs", "robot_msgs.go"))
+	assert.Contains(t, structs, "package robot_msgs")
+	assert.Contains(t, structs, `"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"`)
+	assert.Contains(t, structs, "Pack_LABEL string = \"main # not a comment\"")
+	assert.Contains(t, structs, "type Pack struct {")
+	assert.Contains(t, structs, "Cells           []Cell")
+	assert.Contains(t, structs, "Raw             [4]int8 `rostype:\"byte\"`")
+	assert.Contains(t, structs, "Seen            time.Time")
+	assert.Contains(t, structs, "CellId      int64")
+	assert.Contains(t, structs, "Package_    string `rosname:\"package\"`")
+	assert.Contains(t, structs, "type Reset struct {")
+
+	registry := read(t, filepath.Join(out, "robot_msgs_generated.go"))
+	assert.Contains(t, registry, `"example.com/bridge/messages/robot_msgs"`)
+	assert.Contains(t, registry, `"robot_msgs/Pack":          robot_msgs.Pack{},`)
+	assert.Contains(t, registry, `"robot_msgs/ResetResponse": robot_msgs.ResetResponse{},`)
+	assert.Contains(t, registry, "registry.RegisterAll(robot_msgs_generated_registry)")
+
+	tests := read(t, filepath.Join(out, "robot_msgs_generated_test.go"))
+	assert.Contains(t, tests, "func TestGeneratedRobotMsgsCellRoundTrip(t *testing.T)")
+	assert.Contains(t, tests, `sampleMessage("robot_msgs/ResetRequest")`)
+	assert.Contains(t, tests, `registry.MD5("robot_msgs/ResetRequest")`)
+}
+
+func TestRunFromEnvironment(t *testing.T) {
+	rosDir, out := setup(t)
+	t.Setenv(PackagesEnv, rosDir)
+	assert.Nil(t, Run([]string{"-out", out}))
+	assert.FileExists(t, filepath.Join(out, "robot_msgs_generated.go"))
+
+	t.Setenv(PackagesEnv, "")
+	assert.ErrorIs(t, Run([]string{"-out", out}), ErrNoPackages)
+}
+
+func TestGenerateErrors(t *testing.T) {
+	assert.ErrorIs(t, Generate(nil, "messages"), ErrNoPackages)
+
+	rosDir, out := setup(t)
+	writeTestFile(t, filepath.Join(rosDir, "msg", "Broken.msg"), "unknown_msgs/Thing thing\n")
+	assert.ErrorIs(t, Generate([]string{rosDir}, out), messages.ErrTypeNotFound)
+}
+
+func TestCamelToSnake(t *testing.T) {
+	assert.Equal(t, "frame_id", camelToSnake("FrameId"))
+	assert.Equal(t, "x", camelToSnake("X"))
+	assert.Equal(t, "a_b_c", camelToSnake("ABC"))
+}
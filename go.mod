@@ -5,6 +5,7 @@ go 1.21.6
 require (
 	github.com/bluenviron/goroslib/v2 v2.1.4
 	github.com/stretchr/testify v1.8.4
+	go.uber.org/goleak v1.3.0
 	go.viam.com/rdk v0.20.1-0.20240209215422-1764cb9007e8
 	go.viam.com/utils v0.1.61
 )
@@ -119,7 +120,6 @@ require (
 	go.opentelemetry.io/otel/metric v1.23.1 // indirect
 	go.opentelemetry.io/otel/trace v1.23.1 // indirect
 	go.uber.org/atomic v1.11.0 // indirect
-	go.uber.org/goleak v1.3.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.26.0 // indirect
 	go.viam.com/api v0.1.266 // indirect
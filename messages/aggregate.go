@@ -0,0 +1,231 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var ErrInvalidAggregations = errors.New("invalid aggregations")
+
+// AggregationsKey is the readings key of the aggregations, it can't clash with message fields,
+// which are capitalized
+const AggregationsKey = "aggregations"
+
+// Stat is a statistic of a field over the window.
+type Stat string
+
+const (
+	StatMean   Stat = "mean"
+	StatMin    Stat = "min"
+	StatMax    Stat = "max"
+	StatStddev Stat = "stddev"
+	StatCount  Stat = "count"
+	// StatRate is the number of values per second
+	StatRate Stat = "rate"
+)
+
+var allStats = []Stat{StatMean, StatMin, StatMax, StatStddev, StatCount, StatRate}
+
+// Aggregations are statistics of numeric message fields over a sliding window of time.
+type Aggregations struct {
+	// WindowSecs is how far back the window goes from the last message
+	WindowSecs float64 `json:"window_secs"`
+	// Fields are the paths of the fields in the message, eg: "Data" or "LinearAcceleration.X"
+	Fields []string `json:"fields"`
+	// Stats are computed for every field, all of them by default
+	Stats []Stat `json:"stats"`
+}
+
+func (a *Aggregations) Validate() error {
+	if a == nil {
+		return nil
+	}
+	if a.WindowSecs <= 0 || math.IsInf(a.WindowSecs, 0) || math.IsNaN(a.WindowSecs) {
+		return fmt.Errorf("%w: window_secs must be a positive number of seconds", ErrInvalidAggregations)
+	}
+	if len(a.Fields) == 0 {
+		return fmt.Errorf("%w: fields are required", ErrInvalidAggregations)
+	}
+	for _, f := range a.Fields {
+		if !validPath(f) {
+			return fmt.Errorf("%w: %q is not a valid field path", ErrInvalidAggregations, f)
+		}
+	}
+	for _, s := range a.Stats {
+		switch s {
+		case StatMean, StatMin, StatMax, StatStddev, StatCount, StatRate:
+		default:
+			return fmt.Errorf("%w: %q, stats must be among %q", ErrInvalidAggregations, string(s), allStats)
+		}
+	}
+	return nil
+}
+
+// aggregator updates the statistics of every field with each message, in constant time on
+// average however many messages the window holds.
+type aggregator struct {
+	window time.Duration
+	stats  []Stat
+	fields []string
+
+	// mu guards values, messages are added by the subscriber while readings read them
+	mu     sync.Mutex
+	values []*fieldWindow
+}
+
+func newAggregator(conf *Aggregations) *aggregator {
+	a := &aggregator{
+		window: time.Duration(conf.WindowSecs * float64(time.Second)),
+		stats:  conf.Stats,
+		fields: conf.Fields,
+	}
+	if len(a.stats) == 0 {
+		a.stats = allStats
+	}
+	for range a.fields {
+		a.values = append(a.values, &fieldWindow{})
+	}
+	return a
+}
+
+// add adds the fields of m, a message received at at, and returns the statistics of every field
+// by path. Missing, non numeric and non finite values are left out.
+func (a *aggregator) add(at time.Time, m map[string]interface{}) map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make(map[string]interface{}, len(a.fields))
+	for i, path := range a.fields {
+		w := a.values[i]
+		w.expire(at.Add(-a.window))
+		if v, ok := getPath(m, path); ok && v != nil {
+			if f, ok := toFloat(reflect.ValueOf(v)); ok && !math.IsNaN(f) && !math.IsInf(f, 0) {
+				w.push(at, f)
+			}
+		}
+		out[path] = w.summary(a.stats, at, a.window)
+	}
+	return out
+}
+
+// at returns the statistics of every field over the window ending at now, without the values
+// that left it since the last message.
+func (a *aggregator) at(now time.Time) map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make(map[string]interface{}, len(a.fields))
+	for i, path := range a.fields {
+		w := a.values[i]
+		w.expire(now.Add(-a.window))
+		out[path] = w.summary(a.stats, now, a.window)
+	}
+	return out
+}
+
+type windowSample struct {
+	at  time.Time
+	v   float64
+	seq uint64
+}
+
+// fieldWindow holds the values of a field in the window. The sums are of the values minus the
+// first value ever added, which keeps the variance precise for values far from zero. mins and
+// maxs are the samples that can still become the minimum or maximum once older ones expire.
+// first is when the first value ever was added.
+type fieldWindow struct {
+	first      time.Time
+	samples    []windowSample
+	mins, maxs []windowSample
+	seq        uint64
+	shift      float64
+	sum, sumSq float64
+}
+
+func (w *fieldWindow) push(at time.Time, v float64) {
+	if w.seq == 0 {
+		w.shift, w.first = v, at
+	}
+	w.seq++
+	s := windowSample{at, v, w.seq}
+	w.samples = append(w.samples, s)
+	d := v - w.shift
+	w.sum += d
+	w.sumSq += d * d
+	for len(w.mins) > 0 && w.mins[len(w.mins)-1].v >= v {
+		w.mins = w.mins[:len(w.mins)-1]
+	}
+	w.mins = append(w.mins, s)
+	for len(w.maxs) > 0 && w.maxs[len(w.maxs)-1].v <= v {
+		w.maxs = w.maxs[:len(w.maxs)-1]
+	}
+	w.maxs = append(w.maxs, s)
+}
+
+// expire drops the samples from before start.
+func (w *fieldWindow) expire(start time.Time) {
+	for len(w.samples) > 0 && w.samples[0].at.Before(start) {
+		s := w.samples[0]
+		w.samples = w.samples[1:]
+		d := s.v - w.shift
+		w.sum -= d
+		w.sumSq -= d * d
+		if w.mins[0].seq == s.seq {
+			w.mins = w.mins[1:]
+		}
+		if w.maxs[0].seq == s.seq {
+			w.maxs = w.maxs[1:]
+		}
+	}
+	if len(w.samples) == 0 {
+		// start the sums over so rounding errors don't add up
+		w.sum, w.sumSq = 0, 0
+	}
+}
+
+// summary returns stats of the window ending at now, only the count and rate when it is empty.
+func (w *fieldWindow) summary(stats []Stat, now time.Time, window time.Duration) map[string]interface{} {
+	n := float64(len(w.samples))
+	out := make(map[string]interface{}, len(stats))
+	for _, stat := range stats {
+		switch stat {
+		case StatCount:
+			out["count"] = len(w.samples)
+		case StatRate:
+			out["rate"] = w.rate(now, window)
+		}
+		if len(w.samples) == 0 {
+			continue
+		}
+		mean := w.sum / n
+		switch stat {
+		case StatMean:
+			out["mean"] = mean + w.shift
+		case StatMin:
+			out["min"] = w.mins[0].v
+		case StatMax:
+			out["max"] = w.maxs[0].v
+		case StatStddev:
+			out["stddev"] = math.Sqrt(math.Max(0, w.sumSq/n-mean*mean))
+		}
+	}
+	return out
+}
+
+// rate returns the number of values per second in the window ending at now, so it drops as a
+// topic goes quiet. Until a whole window has passed since the first value, it is over the time
+// since then instead.
+func (w *fieldWindow) rate(now time.Time, window time.Duration) float64 {
+	if len(w.samples) == 0 {
+		return 0
+	}
+	if elapsed := now.Sub(w.first); elapsed < window {
+		if elapsed <= 0 {
+			return 0
+		}
+		return float64(len(w.samples)-1) / elapsed.Seconds()
+	}
+	return float64(len(w.samples)) / window.Seconds()
+}
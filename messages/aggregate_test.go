@@ -0,0 +1,111 @@
+package messages
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+	"github.com/stretchr/testify/assert"
+	"go.viam.com/rdk/logging"
+)
+
+func TestAggregations(t *testing.T) {
+	a := newAggregator(&Aggregations{WindowSecs: 10, Fields: []string{"Data"}})
+	start := time.Now()
+	var stats map[string]interface{}
+	for i, v := range []float64{4, 8, 6, 2} {
+		stats = a.add(start.Add(time.Duration(i)*time.Second), map[string]interface{}{"Data": v})["Data"].(map[string]interface{})
+	}
+	assert.Equal(t, 5.0, stats["mean"])
+	assert.Equal(t, 2.0, stats["min"])
+	assert.Equal(t, 8.0, stats["max"])
+	assert.InDelta(t, math.Sqrt(5), stats["stddev"], 1e-9)
+	assert.Equal(t, 4, stats["count"])
+	assert.InDelta(t, 1.0, stats["rate"], 1e-9)
+
+	// the window moved past the first three values
+	stats = a.add(start.Add(13*time.Second), map[string]interface{}{"Data": "NaN"})["Data"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"mean": 2.0, "min": 2.0, "max": 2.0, "stddev": 0.0, "count": 1, "rate": 0.1}, stats, "Should leave out non finite values")
+	stats = a.add(start.Add(30*time.Second), map[string]interface{}{})["Data"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"count": 0, "rate": 0.0}, stats, "Should only count an empty window")
+
+	a = newAggregator(&Aggregations{WindowSecs: 1, Fields: []string{"Data"}, Stats: []Stat{StatMax}})
+	stats = a.add(start, map[string]interface{}{"Data": 1.0})["Data"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"max": 1.0}, stats, "Should only compute the configured stats")
+}
+
+func TestAggregationsRate(t *testing.T) {
+	a := newAggregator(&Aggregations{WindowSecs: 10, Fields: []string{"Data"}, Stats: []Stat{StatRate}})
+	start := time.Now()
+	rate := func(stats map[string]interface{}) interface{} {
+		return stats["Data"].(map[string]interface{})["rate"]
+	}
+	assert.Equal(t, 0.0, rate(a.add(start, map[string]interface{}{"Data": 1.0})), "Should not have a rate with a single value")
+	for i := 1; i <= 30; i++ {
+		stats := a.add(start.Add(time.Duration(i)*500*time.Millisecond), map[string]interface{}{"Data": 1.0})
+		// a full window also counts the value right on its start
+		assert.InDelta(t, 2.0, rate(stats), 0.1+1e-9, "Should be 2Hz while the window fills and once it is full")
+	}
+	assert.InDelta(t, 0.5, rate(a.at(start.Add(23*time.Second))), 1e-9, "Should drop as the topic goes quiet")
+	assert.Equal(t, 0.0, rate(a.at(start.Add(time.Minute))))
+}
+
+func TestAggregationsMatchWindow(t *testing.T) {
+	a := newAggregator(&Aggregations{WindowSecs: 5, Fields: []string{"Data"}})
+	start := time.Now()
+	rnd := rand.New(rand.NewSource(1))
+	type sample struct {
+		at time.Time
+		v  float64
+	}
+	var samples []sample
+	at := start
+	for i := 0; i < 2000; i++ {
+		at = at.Add(time.Duration(rnd.Intn(500)) * time.Millisecond)
+		v := 1e6 + rnd.NormFloat64()
+		samples = append(samples, sample{at, v})
+		stats := a.add(at, map[string]interface{}{"Data": v})["Data"].(map[string]interface{})
+
+		var window []float64
+		for _, s := range samples {
+			if !s.at.Before(at.Add(-5 * time.Second)) {
+				window = append(window, s.v)
+			}
+		}
+		min, max, sum := math.Inf(1), math.Inf(-1), 0.0
+		for _, v := range window {
+			min, max, sum = math.Min(min, v), math.Max(max, v), sum+v
+		}
+		mean := sum / float64(len(window))
+		variance := 0.0
+		for _, v := range window {
+			variance += (v - mean) * (v - mean)
+		}
+		assert.Equal(t, len(window), stats["count"])
+		assert.Equal(t, min, stats["min"])
+		assert.Equal(t, max, stats["max"])
+		assert.InDelta(t, mean, stats["mean"], 1e-6)
+		assert.InDelta(t, math.Sqrt(variance/float64(len(window))), stats["stddev"], 1e-6)
+	}
+}
+
+func TestHandlerAggregates(t *testing.T) {
+	var last map[string]interface{}
+	handler := NewMessageHandler(logging.NewTestLogger(t), func(m map[string]interface{}) { last = m })
+	handler.Aggregate(&Aggregations{WindowSecs: 60, Fields: []string{"Data"}, Stats: []Stat{StatMean, StatCount}})
+	assert.Nil(t, handler.handleMessage(&std_msgs.Int32{Data: 1}), "Error should be nil")
+	assert.Nil(t, handler.handleMessage(&std_msgs.Int32{Data: 3}), "Error should be nil")
+	assert.Equal(t, 3.0, last["Data"])
+	assert.Equal(t, map[string]interface{}{"Data": map[string]interface{}{"mean": 2.0, "count": 2}}, last[AggregationsKey])
+	assert.Equal(t, last[AggregationsKey], handler.Aggregations(time.Now()), "Should keep the window while it is current")
+	assert.Equal(t, map[string]interface{}{"Data": map[string]interface{}{"count": 0}}, handler.Aggregations(time.Now().Add(time.Minute+time.Second)),
+		"Should expire the window of a quiet topic")
+	assert.Nil(t, NewMessageHandler(logging.NewTestLogger(t), nil).Aggregations(time.Now()))
+
+	assert.Nil(t, (&Aggregations{WindowSecs: 1, Fields: []string{"Data"}}).Validate(), "Error should be nil")
+	assert.ErrorIs(t, (&Aggregations{Fields: []string{"Data"}}).Validate(), ErrInvalidAggregations)
+	assert.ErrorIs(t, (&Aggregations{WindowSecs: 1}).Validate(), ErrInvalidAggregations)
+	assert.ErrorIs(t, (&Aggregations{WindowSecs: 1, Fields: []string{"Data"}, Stats: []Stat{"median"}}).Validate(), ErrInvalidAggregations)
+}
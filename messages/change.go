@@ -0,0 +1,92 @@
+package messages
+
+import (
+	"math"
+	"reflect"
+	"time"
+)
+
+// Changed reports whether next, a converted message, differs from prev by more than deadband in
+// a numeric field, or at all in any other field. The header is ignored since it is stamped for
+// every sample. NaN equals NaN, so a field that stays NaN is unchanged. A nil prev always changed.
+func Changed(prev interface{}, next interface{}, deadband float64) bool {
+	a, b := reflect.ValueOf(prev), reflect.ValueOf(next)
+	if !a.IsValid() || !b.IsValid() || a.Type() != b.Type() {
+		return true
+	}
+	if a.Kind() == reflect.Pointer {
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() != b.IsNil()
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	if a.Kind() != reflect.Struct {
+		return changedValue(a, b, deadband)
+	}
+	for _, f := range messageFields(a.Type()) {
+		if f.Name == "Header" && f.Type == headerType {
+			continue
+		}
+		if changedValue(a.FieldByIndex(f.Index), b.FieldByIndex(f.Index), deadband) {
+			return true
+		}
+	}
+	return false
+}
+
+func changedValue(a, b reflect.Value, deadband float64) bool {
+	switch a.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return changedFloat(a.Float(), b.Float(), deadband)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if a.Int() == b.Int() {
+			return false
+		}
+		return deadband == 0 || math.Abs(float64(a.Int())-float64(b.Int())) > deadband
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if a.Uint() == b.Uint() {
+			return false
+		}
+		return deadband == 0 || math.Abs(float64(a.Uint())-float64(b.Uint())) > deadband
+	case reflect.Bool:
+		return a.Bool() != b.Bool()
+	case reflect.String:
+		return a.String() != b.String()
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return true
+		}
+		for i := 0; i < a.Len(); i++ {
+			if changedValue(a.Index(i), b.Index(i), deadband) {
+				return true
+			}
+		}
+		return false
+	case reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() != b.IsNil()
+		}
+		return changedValue(a.Elem(), b.Elem(), deadband)
+	case reflect.Struct:
+		if a.Type() == timeType {
+			return !a.Interface().(time.Time).Equal(b.Interface().(time.Time))
+		}
+		for _, f := range messageFields(a.Type()) {
+			if changedValue(a.FieldByIndex(f.Index), b.FieldByIndex(f.Index), deadband) {
+				return true
+			}
+		}
+		return false
+	}
+	return !reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func changedFloat(a, b float64, deadband float64) bool {
+	if a == b || (math.IsNaN(a) && math.IsNaN(b)) {
+		return false
+	}
+	if math.IsNaN(a) || math.IsNaN(b) || math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return true
+	}
+	return math.Abs(a-b) > deadband
+}
@@ -0,0 +1,44 @@
+package messages
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChanged(t *testing.T) {
+	a := &ThrottlingStates{Throttled: true}
+	b := &ThrottlingStates{Throttled: true}
+	assert.True(t, Changed(nil, a, 0), "Should always publish the first message")
+	assert.False(t, Changed(a, b, 0))
+	b.Header = std_msgs.Header{Seq: 2, Stamp: time.Now(), FrameId: "pi"}
+	assert.False(t, Changed(a, b, 0), "The header should be ignored")
+	b.Undervoltage = true
+	assert.True(t, Changed(a, b, 0))
+
+	assert.False(t, Changed(&std_msgs.Float64{Data: math.NaN()}, &std_msgs.Float64{Data: math.NaN()}, 0), "NaN should equal NaN")
+	assert.True(t, Changed(&std_msgs.Float64{Data: math.NaN()}, &std_msgs.Float64{Data: 1}, 10))
+	assert.True(t, Changed(&std_msgs.Float64{Data: math.Inf(1)}, &std_msgs.Float64{Data: 1}, 10))
+	assert.True(t, Changed(&std_msgs.Int32{Data: 1}, &std_msgs.Int32{Data: 2}, 0))
+	assert.True(t, Changed(&std_msgs.Int32{Data: 1}, &std_msgs.Float32{Data: 1}, 0), "Different types should have changed")
+}
+
+func TestChangedDeadband(t *testing.T) {
+	prev := &sensor_msgs.Imu{}
+	prev.LinearAcceleration.Z = 9.81
+	next := *prev
+	next.LinearAcceleration.Z = 9.85
+	assert.False(t, Changed(prev, &next, 0.1), "Moves within the deadband should not count")
+	next.LinearAcceleration.Z = 9.95
+	assert.True(t, Changed(prev, &next, 0.1))
+
+	assert.False(t, Changed(&std_msgs.Int32{Data: 100}, &std_msgs.Int32{Data: 102}, 5))
+	assert.True(t, Changed(&std_msgs.Int32{Data: 100}, &std_msgs.Int32{Data: 106}, 5))
+	assert.False(t, Changed(&std_msgs.UInt8MultiArray{Data: []uint8{10, 20}}, &std_msgs.UInt8MultiArray{Data: []uint8{11, 19}}, 2))
+	assert.True(t, Changed(&std_msgs.UInt8MultiArray{Data: []uint8{10, 20}}, &std_msgs.UInt8MultiArray{Data: []uint8{10}}, 2), "Lengths should be compared exactly")
+	assert.True(t, Changed(&std_msgs.String{Data: "a"}, &std_msgs.String{Data: "b"}, 100), "Strings should be compared exactly")
+}
@@ -0,0 +1,127 @@
+package messages
+
+import (
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/actionlib_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/diagnostic_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/nav_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/shape_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/stereo_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/trajectory_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/visualization_msgs"
+)
+
+// Registries for the ROS common_msgs stack, using the structs shipped by goroslib
+var actionlib_msgs_registry = Registrations{
+	"actionlib_msgs/GoalID":          actionlib_msgs.GoalID{},
+	"actionlib_msgs/GoalStatus":      actionlib_msgs.GoalStatus{},
+	"actionlib_msgs/GoalStatusArray": actionlib_msgs.GoalStatusArray{},
+}
+
+var diagnostic_msgs_registry = Registrations{
+	"diagnostic_msgs/DiagnosticArray":  diagnostic_msgs.DiagnosticArray{},
+	"diagnostic_msgs/DiagnosticStatus": diagnostic_msgs.DiagnosticStatus{},
+	"diagnostic_msgs/KeyValue":         diagnostic_msgs.KeyValue{},
+}
+
+var geometry_msgs_registry = Registrations{
+	"geometry_msgs/Accel":                      geometry_msgs.Accel{},
+	"geometry_msgs/AccelStamped":               geometry_msgs.AccelStamped{},
+	"geometry_msgs/AccelWithCovariance":        geometry_msgs.AccelWithCovariance{},
+	"geometry_msgs/AccelWithCovarianceStamped": geometry_msgs.AccelWithCovarianceStamped{},
+	"geometry_msgs/Inertia":                    geometry_msgs.Inertia{},
+	"geometry_msgs/InertiaStamped":             geometry_msgs.InertiaStamped{},
+	"geometry_msgs/Point":                      geometry_msgs.Point{},
+	"geometry_msgs/Point32":                    geometry_msgs.Point32{},
+	"geometry_msgs/PointStamped":               geometry_msgs.PointStamped{},
+	"geometry_msgs/Polygon":                    geometry_msgs.Polygon{},
+	"geometry_msgs/PolygonStamped":             geometry_msgs.PolygonStamped{},
+	"geometry_msgs/Pose":                       geometry_msgs.Pose{},
+	"geometry_msgs/Pose2D":                     geometry_msgs.Pose2D{},
+	"geometry_msgs/PoseArray":                  geometry_msgs.PoseArray{},
+	"geometry_msgs/PoseStamped":                geometry_msgs.PoseStamped{},
+	"geometry_msgs/PoseWithCovariance":         geometry_msgs.PoseWithCovariance{},
+	"geometry_msgs/PoseWithCovarianceStamped":  geometry_msgs.PoseWithCovarianceStamped{},
+	"geometry_msgs/Quaternion":                 geometry_msgs.Quaternion{},
+	"geometry_msgs/QuaternionStamped":          geometry_msgs.QuaternionStamped{},
+	"geometry_msgs/Transform":                  geometry_msgs.Transform{},
+	"geometry_msgs/TransformStamped":           geometry_msgs.TransformStamped{},
+	"geometry_msgs/Twist":                      geometry_msgs.Twist{},
+	"geometry_msgs/TwistStamped":               geometry_msgs.TwistStamped{},
+	"geometry_msgs/TwistWithCovariance":        geometry_msgs.TwistWithCovariance{},
+	"geometry_msgs/TwistWithCovarianceStamped": geometry_msgs.TwistWithCovarianceStamped{},
+	"geometry_msgs/Vector3":                    geometry_msgs.Vector3{},
+	"geometry_msgs/Vector3Stamped":             geometry_msgs.Vector3Stamped{},
+	"geometry_msgs/Wrench":                     geometry_msgs.Wrench{},
+	"geometry_msgs/WrenchStamped":              geometry_msgs.WrenchStamped{},
+}
+
+var nav_msgs_registry = Registrations{
+	"nav_msgs/GridCells":     nav_msgs.GridCells{},
+	"nav_msgs/MapMetaData":   nav_msgs.MapMetaData{},
+	"nav_msgs/OccupancyGrid": nav_msgs.OccupancyGrid{},
+	"nav_msgs/Odometry":      nav_msgs.Odometry{},
+	"nav_msgs/Path":          nav_msgs.Path{},
+}
+
+var sensor_msgs_registry = Registrations{
+	"sensor_msgs/BatteryState":       sensor_msgs.BatteryState{},
+	"sensor_msgs/CameraInfo":         sensor_msgs.CameraInfo{},
+	"sensor_msgs/ChannelFloat32":     sensor_msgs.ChannelFloat32{},
+	"sensor_msgs/CompressedImage":    sensor_msgs.CompressedImage{},
+	"sensor_msgs/FluidPressure":      sensor_msgs.FluidPressure{},
+	"sensor_msgs/Illuminance":        sensor_msgs.Illuminance{},
+	"sensor_msgs/Image":              sensor_msgs.Image{},
+	"sensor_msgs/Imu":                sensor_msgs.Imu{},
+	"sensor_msgs/JointState":         sensor_msgs.JointState{},
+	"sensor_msgs/Joy":                sensor_msgs.Joy{},
+	"sensor_msgs/JoyFeedback":        sensor_msgs.JoyFeedback{},
+	"sensor_msgs/JoyFeedbackArray":   sensor_msgs.JoyFeedbackArray{},
+	"sensor_msgs/LaserEcho":          sensor_msgs.LaserEcho{},
+	"sensor_msgs/LaserScan":          sensor_msgs.LaserScan{},
+	"sensor_msgs/MagneticField":      sensor_msgs.MagneticField{},
+	"sensor_msgs/MultiDOFJointState": sensor_msgs.MultiDOFJointState{},
+	"sensor_msgs/MultiEchoLaserScan": sensor_msgs.MultiEchoLaserScan{},
+	"sensor_msgs/NavSatFix":          sensor_msgs.NavSatFix{},
+	"sensor_msgs/NavSatStatus":       sensor_msgs.NavSatStatus{},
+	"sensor_msgs/PointCloud":         sensor_msgs.PointCloud{},
+	"sensor_msgs/PointCloud2":        sensor_msgs.PointCloud2{},
+	"sensor_msgs/PointField":         sensor_msgs.PointField{},
+	"sensor_msgs/Range":              sensor_msgs.Range{},
+	"sensor_msgs/RegionOfInterest":   sensor_msgs.RegionOfInterest{},
+	"sensor_msgs/RelativeHumidity":   sensor_msgs.RelativeHumidity{},
+	"sensor_msgs/Temperature":        sensor_msgs.Temperature{},
+	"sensor_msgs/TimeReference":      sensor_msgs.TimeReference{},
+}
+
+var shape_msgs_registry = Registrations{
+	"shape_msgs/Mesh":           shape_msgs.Mesh{},
+	"shape_msgs/MeshTriangle":   shape_msgs.MeshTriangle{},
+	"shape_msgs/Plane":          shape_msgs.Plane{},
+	"shape_msgs/SolidPrimitive": shape_msgs.SolidPrimitive{},
+}
+
+var stereo_msgs_registry = Registrations{
+	"stereo_msgs/DisparityImage": stereo_msgs.DisparityImage{},
+}
+
+var trajectory_msgs_registry = Registrations{
+	"trajectory_msgs/JointTrajectory":              trajectory_msgs.JointTrajectory{},
+	"trajectory_msgs/JointTrajectoryPoint":         trajectory_msgs.JointTrajectoryPoint{},
+	"trajectory_msgs/MultiDOFJointTrajectory":      trajectory_msgs.MultiDOFJointTrajectory{},
+	"trajectory_msgs/MultiDOFJointTrajectoryPoint": trajectory_msgs.MultiDOFJointTrajectoryPoint{},
+}
+
+var visualization_msgs_registry = Registrations{
+	"visualization_msgs/ImageMarker":               visualization_msgs.ImageMarker{},
+	"visualization_msgs/InteractiveMarker":         visualization_msgs.InteractiveMarker{},
+	"visualization_msgs/InteractiveMarkerControl":  visualization_msgs.InteractiveMarkerControl{},
+	"visualization_msgs/InteractiveMarkerFeedback": visualization_msgs.InteractiveMarkerFeedback{},
+	"visualization_msgs/InteractiveMarkerInit":     visualization_msgs.InteractiveMarkerInit{},
+	"visualization_msgs/InteractiveMarkerPose":     visualization_msgs.InteractiveMarkerPose{},
+	"visualization_msgs/InteractiveMarkerUpdate":   visualization_msgs.InteractiveMarkerUpdate{},
+	"visualization_msgs/Marker":                    visualization_msgs.Marker{},
+	"visualization_msgs/MarkerArray":               visualization_msgs.MarkerArray{},
+	"visualization_msgs/MenuEntry":                 visualization_msgs.MenuEntry{},
+}
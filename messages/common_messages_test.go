@@ -0,0 +1,78 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/diagnostic_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/nav_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommonMessagesRegistered(t *testing.T) {
+	for typeName, sum := range map[string]string{
+		"sensor_msgs/Imu":                 "6a62c6daae103f4ff57a132d6f95cec2",
+		"sensor_msgs/NavSatFix":           "2d3a8cd499b9b4a0249fb98fd05cfa48",
+		"sensor_msgs/BatteryState":        "4ddae7f048e32fda22cac764685e3974",
+		"geometry_msgs/Twist":             "9f195f881246fdfa2798d1d3eebca84a",
+		"nav_msgs/Odometry":               "cd5e73d190d741a2f92e81eda573aca7",
+		"diagnostic_msgs/DiagnosticArray": "60810da900de1dd6ddd437c3503511da",
+	} {
+		m, err := GetMessageType(typeName)
+		assert.Nil(t, err, "Error should be nil for %v", typeName)
+		assert.Equal(t, sum, md5Of(t, m), "MD5 should match ROS for %v", typeName)
+	}
+}
+
+func TestConvertTwistReadings(t *testing.T) {
+	m, e := ConvertToRosMsg("geometry_msgs/Twist", map[string]interface{}{
+		"Linear":  map[string]interface{}{"X": 1.5},
+		"Angular": map[string]interface{}{"Z": -0.5},
+	})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, 1.5, m.(*geometry_msgs.Twist).Linear.X, "Linear.X should be 1.5")
+	assert.Equal(t, -0.5, m.(*geometry_msgs.Twist).Angular.Z, "Angular.Z should be -0.5")
+}
+
+func TestConvertNavSatFixReadings(t *testing.T) {
+	m, e := ConvertToRosMsg("sensor_msgs/NavSatFix", map[string]interface{}{
+		"Latitude":  40.7,
+		"Longitude": -74.0,
+		"Status":    map[string]interface{}{"Status": 0, "Service": 1},
+	})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, 40.7, m.(*sensor_msgs.NavSatFix).Latitude, "Latitude should be 40.7")
+	assert.Equal(t, uint16(1), m.(*sensor_msgs.NavSatFix).Status.Service, "Service should be 1")
+}
+
+func TestConvertBatteryStateReadings(t *testing.T) {
+	m, e := ConvertToRosMsg("sensor_msgs/BatteryState", map[string]interface{}{
+		"Voltage":     12.1,
+		"CellVoltage": []float64{4.0, 4.1, 4.0},
+		"Present":     true,
+	})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, float32(12.1), m.(*sensor_msgs.BatteryState).Voltage, "Voltage should be 12.1")
+	assert.Equal(t, []float32{4.0, 4.1, 4.0}, m.(*sensor_msgs.BatteryState).CellVoltage, "CellVoltage should have 3 cells")
+}
+
+func TestConvertOdometryReadings(t *testing.T) {
+	m, e := ConvertToRosMsg("nav_msgs/Odometry", map[string]interface{}{
+		"ChildFrameId": "base_link",
+		"Pose":         map[string]interface{}{"Pose": map[string]interface{}{"Position": map[string]interface{}{"X": 2.0}}},
+	})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, "base_link", m.(*nav_msgs.Odometry).ChildFrameId, "ChildFrameId should be base_link")
+	assert.Equal(t, 2.0, m.(*nav_msgs.Odometry).Pose.Pose.Position.X, "Position.X should be 2.0")
+}
+
+func TestConvertDiagnosticArrayFromRos(t *testing.T) {
+	r, e := convertFromRosMsg(&diagnostic_msgs.DiagnosticArray{
+		Status: []diagnostic_msgs.DiagnosticStatus{{Level: diagnostic_msgs.DiagnosticStatus_WARN, Name: "battery"}},
+	})
+	assert.Nil(t, e, "Error should be nil")
+	status := r["Status"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "battery", status["Name"], "Name should be battery")
+	assert.Equal(t, 1.0, status["Level"], "Level should be WARN")
+}
@@ -0,0 +1,690 @@
+package messages
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The converter moves values between readings and messages with reflection, giving exactly the
+// result of marshalling to JSON and unmarshalling back, without the encoding. Types and values it
+// doesn't know are converted with encoding/json, and when a conversion fails the whole message
+// is converted with encoding/json again so errors stay the same too.
+//
+// There are two differences. 64-bit integers, which JSON would turn into float64 and round above
+// 2^53, are read as int64 and uint64, and can be given as decimal strings or json.Number too. NaN
+// and ±Inf, which JSON can't encode at all, are kept as they are, and can be given as strings.
+
+var (
+	jsonMarshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType   = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	float32SmallestNormal = math.Float32frombits(0x00800000)
+)
+
+// codec converts one Go type. decode returns false when the value can't be converted the way
+// encoding/json would, encode returns false when encoding/json would fail.
+type codec struct {
+	decode func(src interface{}, dst reflect.Value) bool
+	encode func(v reflect.Value) (interface{}, bool)
+	// viaJSON is set when both directions use encoding/json
+	viaJSON bool
+}
+
+type codecField struct {
+	name  string
+	index int
+	codec *codec
+}
+
+var codecs sync.Map // reflect.Type -> *codec
+
+func codecFor(t reflect.Type) *codec {
+	if c, ok := codecs.Load(t); ok {
+		return c.(*codec)
+	}
+	c, _ := codecs.LoadOrStore(t, buildCodec(t, map[reflect.Type]*codec{}))
+	return c.(*codec)
+}
+
+// buildCodec fills in building as it goes so recursive types end up pointing at themselves.
+func buildCodec(t reflect.Type, building map[reflect.Type]*codec) *codec {
+	if c, ok := codecs.Load(t); ok {
+		return c.(*codec)
+	}
+	if c, ok := building[t]; ok {
+		return c
+	}
+	c := &codec{}
+	building[t] = c
+
+	if t == timeType {
+		c.decode = decodeTime
+		c.encode = encodeTime
+		return c
+	}
+	if hasCustomJSON(t) || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && hasCustomJSON(t.Elem())) {
+		c.useJSON()
+		return c
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		c.decode = decodeBool
+		c.encode = func(v reflect.Value) (interface{}, bool) { return v.Bool(), true }
+	case reflect.String:
+		c.decode = decodeString
+		c.encode = encodeString
+	case reflect.Int, reflect.Int64:
+		c.decode = decodeInt
+		c.encode = func(v reflect.Value) (interface{}, bool) { return v.Int(), true }
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		c.decode = decodeInt
+		c.encode = func(v reflect.Value) (interface{}, bool) { return float64(v.Int()), true }
+	case reflect.Uint, reflect.Uint64:
+		c.decode = decodeUint
+		c.encode = func(v reflect.Value) (interface{}, bool) { return v.Uint(), true }
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		c.decode = decodeUint
+		c.encode = func(v reflect.Value) (interface{}, bool) { return float64(v.Uint()), true }
+	case reflect.Float32:
+		c.decode = decodeFloat32
+		c.encode = encodeFloat32
+	case reflect.Float64:
+		c.decode = decodeFloat64
+		c.encode = encodeFloat64
+	case reflect.Slice, reflect.Array:
+		elem := buildCodec(t.Elem(), building)
+		c.decode = func(src interface{}, dst reflect.Value) bool { return decodeList(elem, src, dst) }
+		c.encode = func(v reflect.Value) (interface{}, bool) { return encodeList(elem, v) }
+	case reflect.Struct:
+		fields, ok := structFields(t, building)
+		if !ok {
+			c.useJSON()
+			break
+		}
+		exact := make(map[string]int, len(fields))
+		folded := make(map[string]int, len(fields))
+		for i, f := range fields {
+			exact[f.name] = i
+			if key, ok := foldKey(f.name); ok {
+				// like encoding/json, the first field wins when names only differ in case
+				if _, ok := folded[key]; !ok {
+					folded[key] = i
+				}
+			}
+		}
+		c.decode = func(src interface{}, dst reflect.Value) bool {
+			return decodeStruct(fields, exact, folded, src, dst)
+		}
+		c.encode = func(v reflect.Value) (interface{}, bool) { return encodeStruct(fields, v) }
+	default:
+		c.useJSON()
+	}
+	return c
+}
+
+func (c *codec) useJSON() {
+	c.decode = jsonDecode
+	c.encode = jsonEncode
+	c.viaJSON = true
+}
+
+func hasCustomJSON(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	return t.Implements(jsonMarshalerType) || p.Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || p.Implements(textMarshalerType) ||
+		p.Implements(jsonUnmarshalerType) || p.Implements(textUnmarshalerType)
+}
+
+// structFields lists the fields encoding/json sees. Embedded structs and json tags other than
+// "-" are left to encoding/json, ROS messages don't use them.
+func structFields(t reflect.Type, building map[reflect.Type]*codec) ([]codecField, bool) {
+	var fields []codecField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				return nil, false
+			}
+			if !f.IsExported() {
+				continue
+			}
+		} else if !f.IsExported() {
+			continue
+		}
+		switch tag := f.Tag.Get("json"); tag {
+		case "-":
+			continue
+		case "":
+		default:
+			return nil, false
+		}
+		if _, ok := foldKey(f.Name); !ok {
+			return nil, false
+		}
+		fields = append(fields, codecField{name: f.Name, index: i, codec: buildCodec(f.Type, building)})
+	}
+	// decodeStruct tracks the fields it has seen in a bit set
+	if len(fields) > 64 {
+		return nil, false
+	}
+	return fields, true
+}
+
+// foldKey is the case folding encoding/json uses to match keys to fields, for ASCII only.
+func foldKey(s string) (string, bool) {
+	var buf [64]byte
+	b := buf[:0]
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			return "", false
+		}
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		b = append(b, c)
+	}
+	return string(b), true
+}
+
+// isPlainValue reports whether v is one of the types readings are usually made of. Anything
+// else is converted with encoding/json.
+func isPlainValue(v interface{}) bool {
+	switch v.(type) {
+	case bool, string, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		json.Number, map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+func decodeValue(c *codec, src interface{}, dst reflect.Value) bool {
+	if src == nil && !c.viaJSON {
+		// null leaves fields alone, and dst is always a zero value
+		return true
+	}
+	if !c.viaJSON && !isPlainValue(src) {
+		return jsonDecode(src, dst)
+	}
+	return c.decode(src, dst)
+}
+
+func jsonDecode(src interface{}, dst reflect.Value) bool {
+	b, err := json.Marshal(src)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, dst.Addr().Interface()) == nil
+}
+
+func jsonEncode(v reflect.Value) (interface{}, bool) {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, false
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// marshals reports whether encoding/json can marshal v, for values that are dropped anyway.
+func marshals(v interface{}) bool {
+	switch x := v.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	case map[string]interface{}:
+		for _, e := range x {
+			if !marshals(e) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		for _, e := range x {
+			if !marshals(e) {
+				return false
+			}
+		}
+		return true
+	}
+	_, err := json.Marshal(v)
+	return err == nil
+}
+
+func decodeBool(src interface{}, dst reflect.Value) bool {
+	b, ok := src.(bool)
+	if ok {
+		dst.SetBool(b)
+	}
+	return ok
+}
+
+func decodeString(src interface{}, dst reflect.Value) bool {
+	// encoding/json replaces invalid UTF-8
+	s, ok := src.(string)
+	if !ok || !utf8.ValidString(s) {
+		return false
+	}
+	dst.SetString(s)
+	return true
+}
+
+func decodeInt(src interface{}, dst reflect.Value) bool {
+	var n int64
+	switch x := src.(type) {
+	case int:
+		n = int64(x)
+	case int8:
+		n = int64(x)
+	case int16:
+		n = int64(x)
+	case int32:
+		n = int64(x)
+	case int64:
+		n = x
+	case uint, uint8, uint16, uint32, uint64:
+		u := reflect.ValueOf(x).Uint()
+		if u > math.MaxInt64 {
+			return false
+		}
+		n = int64(u)
+	case json.Number:
+		i, err := strconv.ParseInt(string(x), 10, 64)
+		if err != nil {
+			return false
+		}
+		n = i
+	case string:
+		i, ok := parseInt64String(x, dst)
+		if !ok {
+			return false
+		}
+		n = i
+	case float64:
+		// integral floats are marshalled without a fraction or exponent below 1e21
+		if x != math.Trunc(x) || x < math.MinInt64 || x >= math.MaxInt64 {
+			return false
+		}
+		n = int64(x)
+		if math.Abs(x) > 1<<53 {
+			// the shortest decimal of large floats is not the float's exact value
+			var buf [32]byte
+			i, err := strconv.ParseInt(string(strconv.AppendFloat(buf[:0], x, 'f', -1, 64)), 10, 64)
+			if err != nil {
+				return false
+			}
+			n = i
+		}
+	default:
+		return false
+	}
+	if dst.OverflowInt(n) {
+		return false
+	}
+	dst.SetInt(n)
+	return true
+}
+
+func decodeUint(src interface{}, dst reflect.Value) bool {
+	var n uint64
+	switch x := src.(type) {
+	case int, int8, int16, int32, int64:
+		i := reflect.ValueOf(x).Int()
+		if i < 0 {
+			return false
+		}
+		n = uint64(i)
+	case uint:
+		n = uint64(x)
+	case uint8:
+		n = uint64(x)
+	case uint16:
+		n = uint64(x)
+	case uint32:
+		n = uint64(x)
+	case uint64:
+		n = x
+	case json.Number:
+		u, err := strconv.ParseUint(string(x), 10, 64)
+		if err != nil {
+			return false
+		}
+		n = u
+	case string:
+		if dst.Kind() != reflect.Uint64 && dst.Kind() != reflect.Uint {
+			return false
+		}
+		u, err := strconv.ParseUint(x, 10, 64)
+		if err != nil {
+			return false
+		}
+		n = u
+	case float64:
+		// -0 is marshalled as "-0", which isn't a valid unsigned integer
+		if x != math.Trunc(x) || x < 0 || math.Signbit(x) || x >= math.MaxUint64 {
+			return false
+		}
+		n = uint64(x)
+		if x > 1<<53 {
+			var buf [32]byte
+			u, err := strconv.ParseUint(string(strconv.AppendFloat(buf[:0], x, 'f', -1, 64)), 10, 64)
+			if err != nil {
+				return false
+			}
+			n = u
+		}
+	default:
+		return false
+	}
+	if dst.OverflowUint(n) {
+		return false
+	}
+	dst.SetUint(n)
+	return true
+}
+
+// parseInt64String reads the decimal strings 64-bit integers can be given as, since the Viam
+// API carries numbers as float64.
+func parseInt64String(s string, dst reflect.Value) (int64, bool) {
+	if dst.Kind() != reflect.Int64 && dst.Kind() != reflect.Int {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return i, err == nil
+}
+
+// numberToFloat64 returns src the way encoding/json would parse it into a float64.
+func numberToFloat64(src interface{}) (float64, bool) {
+	switch x := src.(type) {
+	case float64:
+		return x, true
+	case string:
+		return parseNonFinite(x)
+	case int, int8, int16, int32, int64:
+		return float64(reflect.ValueOf(x).Int()), true
+	case uint, uint8, uint16, uint32, uint64:
+		return float64(reflect.ValueOf(x).Uint()), true
+	case json.Number:
+		f, err := strconv.ParseFloat(string(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func decodeFloat64(src interface{}, dst reflect.Value) bool {
+	f, ok := numberToFloat64(src)
+	if ok {
+		dst.SetFloat(f)
+	}
+	return ok
+}
+
+// decodeFloat32 matches encoding/json rounding the decimal text to a float32. That only differs
+// from rounding the float64 itself when the float64 lies exactly halfway between two float32s,
+// or outside the normal float32 range, so only those go through the text.
+func decodeFloat32(src interface{}, dst reflect.Value) bool {
+	if n, ok := src.(json.Number); ok {
+		f, err := strconv.ParseFloat(string(n), 32)
+		if err != nil {
+			return false
+		}
+		dst.SetFloat(f)
+		return true
+	}
+	f, ok := numberToFloat64(src)
+	if !ok {
+		return false
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		dst.SetFloat(f)
+		return true
+	}
+	switch src.(type) {
+	case float64:
+		a := math.Abs(f)
+		if f != 0 && (a > math.MaxFloat32 || a < float64(float32SmallestNormal) || math.Float64bits(f)&(1<<29-1) == 1<<28) {
+			var buf [32]byte
+			f32, err := strconv.ParseFloat(string(strconv.AppendFloat(buf[:0], f, 'g', -1, 64)), 32)
+			if err != nil {
+				return false
+			}
+			dst.SetFloat(f32)
+			return true
+		}
+	default:
+		// integers above 2^53 were already rounded once by the float64 conversion
+		if math.Abs(f) > 1<<53 {
+			var buf [32]byte
+			var text []byte
+			if rv := reflect.ValueOf(src); rv.CanInt() {
+				text = strconv.AppendInt(buf[:0], rv.Int(), 10)
+			} else {
+				text = strconv.AppendUint(buf[:0], rv.Uint(), 10)
+			}
+			f32, err := strconv.ParseFloat(string(text), 32)
+			if err != nil {
+				return false
+			}
+			dst.SetFloat(f32)
+			return true
+		}
+	}
+	dst.SetFloat(float64(float32(f)))
+	return true
+}
+
+func decodeList(elem *codec, src interface{}, dst reflect.Value) bool {
+	if s, ok := src.(string); ok && dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8 {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return false
+		}
+		dst.SetBytes(b)
+		return true
+	}
+	list, ok := src.([]interface{})
+	if !ok {
+		return false
+	}
+	n := len(list)
+	if dst.Kind() == reflect.Slice {
+		// an empty list gives an empty slice, not nil
+		dst.Set(reflect.MakeSlice(dst.Type(), n, n))
+	} else if n > dst.Len() {
+		for _, e := range list[dst.Len():] {
+			if !marshals(e) {
+				return false
+			}
+		}
+		n = dst.Len()
+	}
+	for i := 0; i < n; i++ {
+		if !decodeValue(elem, list[i], dst.Index(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeStruct(fields []codecField, exact map[string]int, folded map[string]int, src interface{}, dst reflect.Value) bool {
+	data, ok := src.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	var seen uint64
+	for k, v := range data {
+		i, ok := exact[k]
+		if !ok {
+			key, ascii := foldKey(k)
+			if !ascii {
+				return false
+			}
+			i, ok = folded[key]
+		}
+		if !ok {
+			if !marshals(v) {
+				return false
+			}
+			continue
+		}
+		// the result of several keys for one field depends on their order
+		if seen&(1<<i) != 0 {
+			return false
+		}
+		seen |= 1 << i
+		f := fields[i]
+		if !decodeValue(f.codec, v, dst.Field(f.index)) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeString replaces every invalid UTF-8 byte with U+FFFD, like encoding/json.
+func encodeString(v reflect.Value) (interface{}, bool) {
+	s := v.String()
+	if utf8.ValidString(s) {
+		return s, true
+	}
+	b := make([]byte, 0, len(s)+8)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = utf8.AppendRune(b, utf8.RuneError)
+		} else {
+			b = append(b, s[i:i+size]...)
+		}
+		i += size
+	}
+	return string(b), true
+}
+
+func encodeFloat64(v reflect.Value) (interface{}, bool) {
+	return v.Float(), true
+}
+
+// encodeFloat32 gives the float64 of the shortest decimal of the float32, like encoding/json.
+func encodeFloat32(v reflect.Value) (interface{}, bool) {
+	f := v.Float()
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f, true
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<24 {
+		return f, true
+	}
+	var buf [32]byte
+	f, err := strconv.ParseFloat(string(strconv.AppendFloat(buf[:0], f, 'g', -1, 32)), 64)
+	return f, err == nil
+}
+
+func decodeTime(src interface{}, dst reflect.Value) bool {
+	s, ok := src.(string)
+	if !ok {
+		return jsonDecode(src, dst)
+	}
+	return dst.Addr().Interface().(*time.Time).UnmarshalText([]byte(s)) == nil
+}
+
+func encodeTime(v reflect.Value) (interface{}, bool) {
+	b, err := v.Interface().(time.Time).MarshalText()
+	if err != nil {
+		return nil, false
+	}
+	return string(b), true
+}
+
+func encodeList(elem *codec, v reflect.Value) (interface{}, bool) {
+	if v.Kind() == reflect.Slice {
+		if v.IsNil() {
+			return nil, true
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), true
+		}
+	}
+	out := make([]interface{}, v.Len())
+	for i := range out {
+		e, ok := elem.encode(v.Index(i))
+		if !ok {
+			return nil, false
+		}
+		out[i] = e
+	}
+	return out, true
+}
+
+func encodeStruct(fields []codecField, v reflect.Value) (interface{}, bool) {
+	out := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		e, ok := f.codec.encode(v.Field(f.index))
+		if !ok {
+			return nil, false
+		}
+		out[f.name] = e
+	}
+	return out, true
+}
+
+// decodeMessage fills the message m points to from data.
+func decodeMessage(data map[string]interface{}, m interface{}) bool {
+	v := reflect.ValueOf(m)
+	if data == nil || v.Kind() != reflect.Pointer || v.IsNil() {
+		return false
+	}
+	return decodeValue(codecFor(v.Elem().Type()), data, v.Elem())
+}
+
+// encodeMessage converts the message m points to into readings.
+func encodeMessage(m interface{}) (map[string]interface{}, bool) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, false
+	}
+	out, ok := codecFor(v.Elem().Type()).encode(v.Elem())
+	if !ok {
+		return nil, false
+	}
+	data, ok := out.(map[string]interface{})
+	return data, ok
+}
+
+func convertToRosMsgJSON(t interface{}, data map[string]interface{}) (interface{}, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &t)
+	if err != nil {
+		return nil, err
+	}
+	return t, err
+}
+
+func convertFromRosMsgJSON(msg interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapResult map[string]interface{}
+	err = json.Unmarshal(data, &mapResult)
+	if err != nil {
+		return nil, err
+	}
+	return mapResult, nil
+}
@@ -0,0 +1,268 @@
+package messages
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+	"github.com/stretchr/testify/assert"
+)
+
+// fillRandom sets every field of v to a random value.
+func fillRandom(r *rand.Rand, v reflect.Value) {
+	switch {
+	case v.Type() == timeType:
+		v.Set(reflect.ValueOf(time.Unix(r.Int63n(1<<32), r.Int63n(1e9))))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(r.Intn(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(r.Uint64()) >> (64 - v.Type().Bits()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(r.Uint64() >> (64 - v.Type().Bits()))
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(r.Uint32()&^(0xff<<23) | uint32(r.Intn(254)+1)<<23)))
+	case reflect.Float64:
+		v.SetFloat(r.NormFloat64() * math.Pow(10, float64(r.Intn(40)-20)))
+	case reflect.String:
+		b := make([]byte, r.Intn(8))
+		for i := range b {
+			b[i] = byte(' ' + r.Intn(95))
+		}
+		v.SetString(string(b))
+	case reflect.Slice:
+		n := r.Intn(4)
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := 0; i < n; i++ {
+			fillRandom(r, v.Index(i))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fillRandom(r, v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() && !v.Type().Field(i).Anonymous {
+				fillRandom(r, v.Field(i))
+			}
+		}
+	}
+}
+
+// asJSONNumbers turns the 64-bit integers of readings into float64, like encoding/json does.
+func asJSONNumbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int64:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, e := range x {
+			out[k] = asJSONNumbers(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = asJSONNumbers(e)
+		}
+		return out
+	}
+	return v
+}
+
+func TestConverterMatchesJSONForEveryType(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, typeName := range registry.Types() {
+		for i := 0; i < 20; i++ {
+			m, err := GetMessageType(typeName)
+			assert.Nil(t, err, "Error should be nil")
+			fillRandom(r, reflect.ValueOf(m).Elem())
+
+			expected, err := convertFromRosMsgJSON(m)
+			assert.Nil(t, err, "Error should be nil for %v", typeName)
+			actual, ok := encodeMessage(m)
+			assert.True(t, ok, "%v should not need encoding/json", typeName)
+			assert.Equal(t, expected, asJSONNumbers(actual), "%v should convert like encoding/json", typeName)
+
+			back, err := GetMessageType(typeName)
+			assert.Nil(t, err, "Error should be nil")
+			backJSON, err := GetMessageType(typeName)
+			assert.Nil(t, err, "Error should be nil")
+			_, err = convertToRosMsgJSON(backJSON, actual)
+			assert.Nil(t, err, "Error should be nil for %v", typeName)
+			assert.True(t, decodeMessage(actual, back), "%v should not need encoding/json", typeName)
+			assert.Equal(t, backJSON, back, "%v should convert like encoding/json", typeName)
+		}
+	}
+}
+
+func TestConverterMatchesJSONForReadings(t *testing.T) {
+	for _, c := range []struct {
+		typeName string
+		data     map[string]interface{}
+	}{
+		{"std_msgs/UInt8", map[string]interface{}{"Data": 300}},
+		{"std_msgs/UInt8", map[string]interface{}{"Data": -0.0}},
+		{"std_msgs/UInt8", map[string]interface{}{"Data": math.Copysign(0, -1)}},
+		{"std_msgs/Int8", map[string]interface{}{"data": 2.0, "Unknown": []interface{}{1, "a"}}},
+		{"std_msgs/Int8", map[string]interface{}{"Data": 2.0, "data": 3.0}},
+		{"std_msgs/Int32", map[string]interface{}{"Data": 1.5}},
+		{"std_msgs/Int64", map[string]interface{}{"Data": 1e21}},
+		{"std_msgs/Int64", map[string]interface{}{"Data": uint64(math.MaxUint64)}},
+		{"std_msgs/Int64", map[string]interface{}{"Data": json.Number("-9007199254740993")}},
+		{"std_msgs/UInt64", map[string]interface{}{"Data": uint64(math.MaxUint64)}},
+		{"std_msgs/Int32", map[string]interface{}{"Data": json.Number("1.5")}},
+		{"std_msgs/Float32", map[string]interface{}{"Data": json.Number("0.1")}},
+		{"std_msgs/Float32", map[string]interface{}{"Data": uint64(1<<60 + 1)}},
+		{"std_msgs/Float32", map[string]interface{}{"Data": 1e39}},
+		{"std_msgs/Float32", map[string]interface{}{"Data": math.Float64frombits(0x3ff0000010000000)}},
+		{"std_msgs/Float32", map[string]interface{}{"Data": float32(0.1)}},
+		{"std_msgs/Float32", map[string]interface{}{"Data": int64(-1<<60 - 1)}},
+		{"std_msgs/String", map[string]interface{}{"Data": "\xff"}},
+		{"std_msgs/String", map[string]interface{}{"Data": 1}},
+		{"std_msgs/String", map[string]interface{}{"Data": nil}},
+		{"std_msgs/Header", map[string]interface{}{"Stamp": "2024-01-02T03:04:05.5Z", "frameid": "base"}},
+		{"std_msgs/Header", map[string]interface{}{"Stamp": time.Unix(5, 0)}},
+		{"std_msgs/Header", map[string]interface{}{"Stamp": 5}},
+		{"std_msgs/Duration", map[string]interface{}{"Data": 1500}},
+		{"std_msgs/UInt8MultiArray", map[string]interface{}{"Data": "AQID"}},
+		{"std_msgs/UInt8MultiArray", map[string]interface{}{"Data": []interface{}{1, 2, 256}}},
+		{"std_msgs/UInt8MultiArray", map[string]interface{}{"Data": []interface{}{}}},
+		{"std_msgs/UInt8MultiArray", map[string]interface{}{"Data": []byte{1, 2}}},
+		{"std_msgs/Int8MultiArray", map[string]interface{}{"Data": "AQID"}},
+		{"std_msgs/Float64MultiArray", map[string]interface{}{"Data": []float64{1, 2}}},
+		{"geometry_msgs/Vector3", map[string]interface{}{"X": 1, "Y": int8(2), "Z": uint16(3)}},
+		{"geometry_msgs/Twist", map[string]interface{}{"Linear": struct{ X, Y, Z float64 }{X: 1}, "Angular": map[string]float64{"Z": 2}}},
+		{"geometry_msgs/Twist", map[string]interface{}{"Linear": 1}},
+		{"sensor_msgs/NavSatFix", map[string]interface{}{"PositionCovariance": []interface{}{1, 2}}},
+		{"sensor_msgs/NavSatFix", map[string]interface{}{"PositionCovariance": []interface{}{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}},
+		{"sensor_msgs/NavSatFix", map[string]interface{}{"Package": 3}},
+		{"std_msgs/Empty", nil},
+	} {
+		expectedMsg, err := GetMessageType(c.typeName)
+		assert.Nil(t, err, "Error should be nil")
+		expected, expectedErr := convertToRosMsgJSON(expectedMsg, c.data)
+		actual, actualErr := ConvertToRosMsg(c.typeName, c.data)
+		assert.Equal(t, expectedErr, actualErr, "%v %v should fail like encoding/json", c.typeName, c.data)
+		assert.Equal(t, expected, actual, "%v %v should convert like encoding/json", c.typeName, c.data)
+	}
+}
+
+func TestConverterFallsBackForInvalidMessages(t *testing.T) {
+	for _, m := range []interface{}{
+		&std_msgs.Time{Data: time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)},
+		(*std_msgs.Int8)(nil),
+	} {
+		expected, expectedErr := convertFromRosMsgJSON(m)
+		actual, actualErr := convertFromRosMsg(m)
+		assert.Equal(t, expectedErr, actualErr, "%#v should fail like encoding/json", m)
+		assert.Equal(t, expected, actual, "%#v should convert like encoding/json", m)
+	}
+}
+
+func TestConverterKeepsInvalidUTF8LikeJSON(t *testing.T) {
+	m := &std_msgs.String{Data: "a\xff\xfe\xe2\x82b"}
+	expected, err := convertFromRosMsgJSON(m)
+	assert.Nil(t, err, "Error should be nil")
+	actual, ok := encodeMessage(m)
+	assert.True(t, ok, "Invalid UTF-8 should not need encoding/json")
+	assert.Equal(t, expected, asJSONNumbers(actual))
+}
+
+func TestConvert64BitIntegersExactly(t *testing.T) {
+	r, e := convertFromRosMsg(&std_msgs.Int64{Data: 1<<53 + 1})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, int64(1<<53+1), r["Data"], "Data should be exact")
+	r, e = convertFromRosMsg(&std_msgs.UInt64{Data: math.MaxUint64})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, uint64(math.MaxUint64), r["Data"], "Data should be exact")
+	r, e = convertFromRosMsg(&std_msgs.Duration{Data: 1<<62 + 1})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, int64(1<<62+1), r["Data"], "Durations should be exact")
+	r, e = convertFromRosMsg(&std_msgs.Int32{Data: 7})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, 7.0, r["Data"], "Smaller integers should still be float64")
+
+	for _, data := range []interface{}{int64(1<<53 + 1), json.Number("9007199254740993"), "9007199254740993"} {
+		m, e := ConvertToRosMsg("std_msgs/Int64", map[string]interface{}{"Data": data})
+		assert.Nil(t, e, "Error should be nil for %#v", data)
+		assert.Equal(t, int64(1<<53+1), m.(*std_msgs.Int64).Data, "Data should be exact for %#v", data)
+	}
+	m, e := ConvertToRosMsg("std_msgs/UInt64", map[string]interface{}{"Data": "18446744073709551615"})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, uint64(math.MaxUint64), m.(*std_msgs.UInt64).Data, "Data should be exact")
+
+	_, e = ConvertToRosMsg("std_msgs/Int32", map[string]interface{}{"Data": "5"})
+	assert.NotNil(t, e, "Only 64-bit integers can be strings")
+	_, e = ConvertToRosMsg("std_msgs/UInt64", map[string]interface{}{"Data": "-1"})
+	assert.NotNil(t, e, "Error should not be nil")
+}
+
+func imuReadings() map[string]interface{} {
+	m, _ := convertFromRosMsg(&sensor_msgs.Imu{
+		Header:             std_msgs.Header{Seq: 1, Stamp: time.Now(), FrameId: "imu_link"},
+		Orientation:        geometry_msgs.Quaternion{W: 1},
+		LinearAcceleration: geometry_msgs.Vector3{Z: 9.81},
+	})
+	return m
+}
+
+func scan() *sensor_msgs.LaserScan {
+	m := &sensor_msgs.LaserScan{Header: std_msgs.Header{FrameId: "laser"}, AngleIncrement: 0.5}
+	for i := 0; i < 720; i++ {
+		m.Ranges = append(m.Ranges, float32(i)/100)
+		m.Intensities = append(m.Intensities, float32(i%7))
+	}
+	return m
+}
+
+func BenchmarkConvertToRosMsg(b *testing.B) {
+	readings := imuReadings()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ConvertToRosMsg("sensor_msgs/Imu", readings); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvertToRosMsgJSON(b *testing.B) {
+	readings := imuReadings()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		t, _ := GetMessageType("sensor_msgs/Imu")
+		if _, err := convertToRosMsgJSON(t, readings); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvertFromRosMsg(b *testing.B) {
+	m := scan()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := convertFromRosMsg(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvertFromRosMsgJSON(b *testing.B) {
+	m := scan()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := convertFromRosMsgJSON(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
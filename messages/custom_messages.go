@@ -5,20 +5,11 @@ import (
 	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
 )
 
-var custom_type_registry = TypeRegistry{
-	"ThrottlingStates": func() interface{} { return &ThrottlingStates{} },
+var custom_type_registry = Registrations{
+	"ThrottlingStates": ThrottlingStates{},
 	// Add more custom message types here
 }
 
-func GetCustomMsgsCallback(handleMessage func(interface{}) error, typeName string) interface{} {
-	switch typeName {
-	case "ThrottlingStates":
-		return func(msg *ThrottlingStates) { handleMessage(msg) }
-		// Add more custom message types here
-	}
-	return nil
-}
-
 type ThrottlingStates struct {
 	msg.Package                  `ros:"sample_msgs"`
 	Header                       std_msgs.Header `rosname:"header"`
@@ -0,0 +1,421 @@
+package messages
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msg"
+	"github.com/bluenviron/goroslib/v2/pkg/msgproc"
+	"go.viam.com/rdk/logging"
+)
+
+var ErrInvalidDefinition = errors.New("invalid message definition")
+
+var primitiveTypes = map[string]reflect.Type{
+	"bool":     reflect.TypeOf(false),
+	"int8":     reflect.TypeOf(int8(0)),
+	"uint8":    reflect.TypeOf(uint8(0)),
+	"int16":    reflect.TypeOf(int16(0)),
+	"uint16":   reflect.TypeOf(uint16(0)),
+	"int32":    reflect.TypeOf(int32(0)),
+	"uint32":   reflect.TypeOf(uint32(0)),
+	"int64":    reflect.TypeOf(int64(0)),
+	"uint64":   reflect.TypeOf(uint64(0)),
+	"float32":  reflect.TypeOf(float32(0)),
+	"float64":  reflect.TypeOf(float64(0)),
+	"string":   reflect.TypeOf(""),
+	"time":     reflect.TypeOf(time.Time{}),
+	"duration": reflect.TypeOf(time.Duration(0)),
+	// byte and char are aliases that only differ from int8/uint8 in the definition text
+	"byte": reflect.TypeOf(int8(0)),
+	"char": reflect.TypeOf(uint8(0)),
+}
+
+var arrayTypeRegex = regexp.MustCompile(`^(.+?)\[(\d*)\]$`)
+var srvSeparatorRegex = regexp.MustCompile(`(?m)^---\s*$`)
+
+type MessageField struct {
+	Type string
+	Name string
+}
+
+// MessageDefinition is a parsed .msg file, or one half of a .srv file.
+type MessageDefinition struct {
+	Package   string
+	Name      string
+	Constants []string
+	Fields    []MessageField
+}
+
+func (d *MessageDefinition) TypeName() string {
+	return d.Package + "/" + d.Name
+}
+
+// FieldType is a ROS field type split into its element type and array size. Elem is either a
+// primitive (int32, time, ...) or a fully qualified message type (std_msgs/Header).
+type FieldType struct {
+	Elem    string
+	IsArray bool
+	// Len is the size of a fixed size array, 0 for variable length arrays
+	Len int
+}
+
+func IsPrimitiveType(rosType string) bool {
+	_, ok := primitiveTypes[rosType]
+	return ok
+}
+
+// ParseFieldType resolves a field type as written in a .msg file of the given package.
+func ParseFieldType(pkg string, rosType string) (FieldType, error) {
+	ft := FieldType{Elem: rosType}
+	if m := arrayTypeRegex.FindStringSubmatch(rosType); m != nil {
+		ft.Elem = m[1]
+		ft.IsArray = true
+		if m[2] != "" {
+			n, err := strconv.Atoi(m[2])
+			if err != nil {
+				return ft, fmt.Errorf("%w: invalid array size in %v", ErrInvalidDefinition, rosType)
+			}
+			ft.Len = n
+		}
+	}
+
+	if IsPrimitiveType(ft.Elem) {
+		return ft, nil
+	}
+	if ft.Elem == "Header" {
+		ft.Elem = "std_msgs/Header"
+	} else if !strings.Contains(ft.Elem, "/") {
+		ft.Elem = pkg + "/" + ft.Elem
+	}
+	return ft, nil
+}
+
+// LoadMessageDefinitions parses the .msg and .srv files found in paths (files or directories)
+// and registers a type for each of them under "<package>/<Name>". The package is taken from the
+// directory layout, so both <package>/msg/Name.msg and <package>/Name.msg work. Services are
+// registered as <package>/<Name>Request and <package>/<Name>Response.
+//
+// The registry is shared by every component of the module, so a type registered again with
+// another definition is logged: components still using the previous one can't talk to it anymore.
+func LoadMessageDefinitions(logger logging.Logger, paths []string) error {
+	defs, err := ParseMessageDefinitions(paths)
+	if err != nil {
+		return err
+	}
+	types, err := BuildMessageTypes(defs)
+	if err != nil {
+		return err
+	}
+	for name, t := range types {
+		prototype := reflect.New(t).Interface()
+		if previous, err := registry.MD5(name); err == nil {
+			if current, err := msgproc.MD5(reflect.ValueOf(prototype).Elem().Interface()); err == nil && current != previous {
+				logger.Warnf("message type %v is redefined, its MD5 changes from %v to %v", name, previous, current)
+			}
+		}
+		registry.Register(name, prototype)
+	}
+	return nil
+}
+
+// ParseMessageDefinitions parses the .msg and .srv files found in paths without registering them.
+func ParseMessageDefinitions(paths []string) ([]*MessageDefinition, error) {
+	files, err := findMessageFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []*MessageDefinition
+	for _, file := range files {
+		fileDefs, err := parseMessageFile(file)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, fileDefs...)
+	}
+	return defs, nil
+}
+
+// BuildMessageTypes builds a struct type for every definition. Definitions can refer to each
+// other and to any type already in the registry.
+func BuildMessageTypes(defs []*MessageDefinition) (map[string]reflect.Type, error) {
+	b := &typeBuilder{defs: map[string]*MessageDefinition{}, built: map[string]reflect.Type{}, building: map[string]bool{}}
+	for _, def := range defs {
+		b.defs[def.TypeName()] = def
+	}
+	for name := range b.defs {
+		if _, err := b.build(name); err != nil {
+			return nil, err
+		}
+	}
+	return b.built, nil
+}
+
+// DefinitionsChecksum returns a checksum of the names and contents of the .msg and .srv files
+// found in paths, which changes when any of them is edited.
+func DefinitionsChecksum(paths []string) (string, error) {
+	files, err := findMessageFiles(paths)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%v\x00%v\x00", file, len(b))
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func findMessageFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !isDefinitionFile(p) {
+				return nil, fmt.Errorf("%w: %v is not a .msg or .srv file", ErrInvalidDefinition, p)
+			}
+			files = append(files, p)
+			continue
+		}
+		err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && isDefinitionFile(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func isDefinitionFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".msg" || ext == ".srv"
+}
+
+func packageForFile(file string) string {
+	dir := filepath.Dir(file)
+	if base := filepath.Base(dir); base == "msg" || base == "srv" {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.Base(dir)
+}
+
+func parseMessageFile(file string) ([]*MessageDefinition, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pkg := packageForFile(file)
+	ext := filepath.Ext(file)
+	name := strings.TrimSuffix(filepath.Base(file), ext)
+
+	var defs []*MessageDefinition
+	if ext == ".srv" {
+		parts := srvSeparatorRegex.Split(string(b), -1)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%v: %w: a service must have a request and a response", file, ErrInvalidDefinition)
+		}
+		for i, suffix := range []string{"Request", "Response"} {
+			def, err := parseMessageDefinition(pkg, name+suffix, parts[i])
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", file, err)
+			}
+			defs = append(defs, def)
+		}
+		return defs, nil
+	}
+
+	def, err := parseMessageDefinition(pkg, name, string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", file, err)
+	}
+	return append(defs, def), nil
+}
+
+func parseMessageDefinition(pkg string, name string, content string) (*MessageDefinition, error) {
+	def := &MessageDefinition{Package: pkg, Name: name}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			if strings.TrimSpace(stripComment(line)) != "" {
+				return nil, fmt.Errorf("%w: unable to parse line %q", ErrInvalidDefinition, line)
+			}
+			continue
+		}
+		typ, rest := line[:i], strings.TrimSpace(line[i+1:])
+		if strings.HasPrefix(typ, "#") {
+			continue
+		}
+
+		if eq := strings.IndexByte(rest, '='); eq >= 0 && !strings.Contains(rest[:eq], "#") {
+			// string constants keep everything after the '=', including '#'
+			constName, val := strings.TrimSpace(rest[:eq]), rest[eq+1:]
+			if typ != "string" {
+				val = stripComment(val)
+			}
+			def.Constants = append(def.Constants, typ+" "+constName+"="+strings.TrimSpace(val))
+			continue
+		}
+
+		fieldName := strings.TrimSpace(stripComment(rest))
+		if fieldName == "" || strings.ContainsAny(fieldName, " \t") {
+			return nil, fmt.Errorf("%w: unable to parse line %q", ErrInvalidDefinition, line)
+		}
+		def.Fields = append(def.Fields, MessageField{Type: typ, Name: fieldName})
+	}
+	return def, nil
+}
+
+func stripComment(s string) string {
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+type typeBuilder struct {
+	defs     map[string]*MessageDefinition
+	built    map[string]reflect.Type
+	building map[string]bool
+}
+
+func (b *typeBuilder) build(typeName string) (reflect.Type, error) {
+	if t, ok := b.built[typeName]; ok {
+		return t, nil
+	}
+	def := b.defs[typeName]
+	if b.building[typeName] {
+		return nil, fmt.Errorf("%w: %v is recursive", ErrInvalidDefinition, typeName)
+	}
+	b.building[typeName] = true
+	defer delete(b.building, typeName)
+
+	fields := []reflect.StructField{{
+		Name:      "Package",
+		Type:      reflect.TypeOf(msg.Package(0)),
+		Tag:       reflect.StructTag(fmt.Sprintf(`ros:"%v" json:"-"`, def.Package)),
+		Anonymous: true,
+	}}
+	if len(def.Constants) > 0 {
+		fields = append(fields, reflect.StructField{
+			Name:      "Definitions",
+			Type:      reflect.TypeOf(msg.Definitions(0)),
+			Tag:       reflect.StructTag(fmt.Sprintf(`ros:"%v" json:"-"`, strings.Join(def.Constants, ","))),
+			Anonymous: true,
+		})
+	}
+
+	// Package, Name and Definitions are taken by the markers goroslib finds the message by, so
+	// fields called like them get a trailing underscore and keep their ROS name in the tag.
+	names := map[string]bool{"Package": true, "Name": true, "Definitions": true}
+	for _, f := range def.Fields {
+		goName := GoFieldName(f.Name)
+		if !token.IsIdentifier(goName) || !token.IsExported(goName) {
+			return nil, fmt.Errorf("%w: invalid field name %v in %v", ErrInvalidDefinition, f.Name, typeName)
+		}
+		if goName == "Package" || goName == "Name" || goName == "Definitions" {
+			goName += "_"
+		}
+		if names[goName] {
+			return nil, fmt.Errorf("%w: duplicate field %v in %v", ErrInvalidDefinition, goName, typeName)
+		}
+		names[goName] = true
+
+		ft, err := ParseFieldType(def.Package, f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%v.%v: %w", typeName, f.Name, err)
+		}
+		t, err := b.resolve(ft)
+		if err != nil {
+			return nil, fmt.Errorf("%v.%v: %w", typeName, f.Name, err)
+		}
+		tag := fmt.Sprintf(`rosname:"%v"`, f.Name)
+		if ft.Elem == "byte" || ft.Elem == "char" {
+			tag += fmt.Sprintf(` rostype:"%v"`, ft.Elem)
+		}
+		fields = append(fields, reflect.StructField{Name: goName, Type: t, Tag: reflect.StructTag(tag)})
+	}
+
+	fields = append(fields, reflect.StructField{
+		Name:      "Name",
+		Type:      reflect.TypeOf(msg.Name(0)),
+		Tag:       reflect.StructTag(fmt.Sprintf(`ros:"%v" json:"-"`, def.Name)),
+		Anonymous: true,
+	})
+
+	t := reflect.StructOf(fields)
+	b.built[typeName] = t
+	return t, nil
+}
+
+func (b *typeBuilder) resolve(ft FieldType) (reflect.Type, error) {
+	t, err := b.resolveElem(ft.Elem)
+	if err != nil {
+		return nil, err
+	}
+	if !ft.IsArray {
+		return t, nil
+	}
+	if ft.Len == 0 {
+		return reflect.SliceOf(t), nil
+	}
+	return reflect.ArrayOf(ft.Len, t), nil
+}
+
+func (b *typeBuilder) resolveElem(elem string) (reflect.Type, error) {
+	if t, ok := primitiveTypes[elem]; ok {
+		return t, nil
+	}
+	if _, ok := b.defs[elem]; ok {
+		return b.build(elem)
+	}
+	m, err := GetMessageType(elem)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", err, elem)
+	}
+	return reflect.TypeOf(m).Elem(), nil
+}
+
+// GoFieldName converts a ROS field name to the Go field name goroslib would generate for it.
+func GoFieldName(rosName string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range rosName {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,193 @@
+package messages
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgproc"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/diagnostic_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/stretchr/testify/assert"
+	"go.viam.com/rdk/logging"
+)
+
+func writeMsgFile(t *testing.T, dir string, pkg string, name string, content string) {
+	p := filepath.Join(dir, pkg, "msg")
+	assert.Nil(t, os.MkdirAll(p, 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(p, name+".msg"), []byte(content), 0o644))
+}
+
+func md5Of(t *testing.T, m interface{}) string {
+	sum, err := msgproc.MD5(reflect.ValueOf(m).Elem().Interface())
+	assert.Nil(t, err)
+	return sum
+}
+
+func TestLoadMessageDefinitionsImu(t *testing.T) {
+	dir := t.TempDir()
+	writeMsgFile(t, dir, "test_geometry_msgs", "Quaternion", "float64 x\nfloat64 y\nfloat64 z\nfloat64 w\n")
+	writeMsgFile(t, dir, "test_geometry_msgs", "Vector3", "# a vector\nfloat64 x\nfloat64 y\nfloat64 z\n")
+	writeMsgFile(t, dir, "test_sensor_msgs", "Imu", `Header header
+
+test_geometry_msgs/Quaternion orientation
+float64[9] orientation_covariance # Row major about x, y, z axes
+
+test_geometry_msgs/Vector3 angular_velocity
+float64[9] angular_velocity_covariance
+
+test_geometry_msgs/Vector3 linear_acceleration
+float64[9] linear_acceleration_covariance
+`)
+	assert.Nil(t, LoadMessageDefinitions(logging.NewTestLogger(t), []string{dir}))
+
+	m, err := GetMessageType("test_sensor_msgs/Imu")
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, "6a62c6daae103f4ff57a132d6f95cec2", md5Of(t, m), "MD5 should match ROS")
+	assert.Equal(t, md5Of(t, &sensor_msgs.Imu{}), md5Of(t, m), "MD5 should match goroslib")
+
+	typ, err := msgproc.Type(reflect.ValueOf(m).Elem().Interface())
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, "test_sensor_msgs/Imu", typ)
+}
+
+func TestLoadMessageDefinitionsConstants(t *testing.T) {
+	dir := t.TempDir()
+	writeMsgFile(t, dir, "test_diagnostic_msgs", "KeyValue", "string key # what to label this value\nstring value\n")
+	writeMsgFile(t, dir, "test_diagnostic_msgs", "DiagnosticStatus", `byte OK=0
+byte WARN=1
+byte ERROR=2
+byte STALE=3
+
+byte level
+string name
+string message
+string hardware_id
+KeyValue[] values
+`)
+	assert.Nil(t, LoadMessageDefinitions(logging.NewTestLogger(t), []string{dir}))
+
+	m, err := GetMessageType("test_diagnostic_msgs/DiagnosticStatus")
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, "d0ce08bc6e5ba34c7754f563a9cabaf1", md5Of(t, m), "MD5 should match ROS")
+	assert.Equal(t, md5Of(t, &diagnostic_msgs.DiagnosticStatus{}), md5Of(t, m), "MD5 should match goroslib")
+}
+
+func TestLoadMessageDefinitionsReservedFieldNames(t *testing.T) {
+	for _, tc := range []struct {
+		field  string
+		goName string
+		md5    string
+	}{
+		{"name", "Name_", "2752b8a52470b7f4d7a7032d89acd8f2"},
+		{"package", "Package_", "f618d83e379c5e360aebedf87e0820cd"},
+		{"definitions", "Definitions_", "1974a9ad98776c6e95075a0835087b8a"},
+	} {
+		t.Run(tc.field, func(t *testing.T) {
+			dir := t.TempDir()
+			writeMsgFile(t, dir, "reserved_msgs", "Labeled", "int32 OK=1\nstring "+tc.field+"\n")
+			assert.Nil(t, LoadMessageDefinitions(logging.NewTestLogger(t), []string{dir}))
+
+			m, err := GetMessageType("reserved_msgs/Labeled")
+			assert.Nil(t, err, "Error should be nil")
+			typ, err := msgproc.Type(reflect.ValueOf(m).Elem().Interface())
+			assert.Nil(t, err, "Error should be nil")
+			assert.Equal(t, "reserved_msgs/Labeled", typ, "Type should keep its name")
+			assert.Equal(t, tc.md5, md5Of(t, m), "MD5 should match ROS")
+
+			f, ok := reflect.TypeOf(m).Elem().FieldByName(tc.goName)
+			assert.True(t, ok, "Field should be renamed")
+			assert.Equal(t, tc.field, f.Tag.Get("rosname"), "Field should keep its ROS name")
+
+			m, err = ConvertToRosMsg("reserved_msgs/Labeled", map[string]interface{}{tc.goName: "left"})
+			assert.Nil(t, err, "Error should be nil")
+			r, err := convertFromRosMsg(m)
+			assert.Nil(t, err, "Error should be nil")
+			assert.Equal(t, "left", r[tc.goName], "Field should round trip")
+		})
+	}
+}
+
+func TestLoadMessageDefinitionsRedefined(t *testing.T) {
+	dir := t.TempDir()
+	writeMsgFile(t, dir, "sample_msgs", "Gauge", "float32 level\n")
+	logger, logs := logging.NewObservedTestLogger(t)
+	// the registry is shared, a previous run of the test may have left the other definition
+	assert.Nil(t, LoadMessageDefinitions(logger, []string{dir}))
+	warnings := logs.FilterMessageSnippet("sample_msgs/Gauge is redefined").Len()
+	assert.Nil(t, LoadMessageDefinitions(logger, []string{dir}))
+	assert.Equal(t, warnings, logs.FilterMessageSnippet("sample_msgs/Gauge is redefined").Len(), "Loading the same definition should not warn")
+
+	writeMsgFile(t, dir, "sample_msgs", "Gauge", "float64 level\n")
+	assert.Nil(t, LoadMessageDefinitions(logger, []string{dir}))
+	assert.Equal(t, warnings+1, logs.FilterMessageSnippet("sample_msgs/Gauge is redefined").Len(), "A new definition should warn")
+}
+
+func TestConvertDynamicReadings(t *testing.T) {
+	dir := t.TempDir()
+	writeMsgFile(t, dir, "sample_msgs", "Battery", "Header header\nfloat32 voltage\nuint8[] cells\nbool charging\n")
+	assert.Nil(t, LoadMessageDefinitions(logging.NewTestLogger(t), []string{filepath.Join(dir, "sample_msgs", "msg", "Battery.msg")}))
+
+	m, err := ConvertToRosMsg("sample_msgs/Battery", map[string]interface{}{"Voltage": 12.5, "Charging": true})
+	assert.Nil(t, err, "Error should be nil")
+	v := reflect.ValueOf(m).Elem()
+	assert.Equal(t, float32(12.5), v.FieldByName("Voltage").Interface(), "Voltage should be 12.5")
+	assert.Equal(t, true, v.FieldByName("Charging").Interface(), "Charging should be true")
+
+	r, err := convertFromRosMsg(m)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, 12.5, r["Voltage"], "Voltage should be 12.5")
+	assert.NotContains(t, r, "Package", "Markers should not be in the readings")
+}
+
+func TestGetDynamicCallback(t *testing.T) {
+	dir := t.TempDir()
+	writeMsgFile(t, dir, "sample_msgs", "Counter", "uint32 count\n")
+	assert.Nil(t, LoadMessageDefinitions(logging.NewTestLogger(t), []string{dir}))
+
+	var received map[string]interface{}
+	handler := NewMessageHandler(logging.NewTestLogger(t), func(m map[string]interface{}) { received = m })
+	conf, err := handler.GetSubscriberConfigWithHandler("sample_msgs/Counter")
+	assert.Nil(t, err, "Error should be nil")
+
+	m, _ := ConvertToRosMsg("sample_msgs/Counter", map[string]interface{}{"Count": 7})
+	reflect.ValueOf(conf.Callback).Call([]reflect.Value{reflect.ValueOf(m)})
+	assert.Equal(t, 7.0, received["Count"], "Count should be 7")
+}
+
+func TestLoadMessageDefinitionsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeMsgFile(t, dir, "sample_msgs", "Broken", "Unknown thing\n")
+	assert.ErrorIs(t, LoadMessageDefinitions(logging.NewTestLogger(t), []string{dir}), ErrTypeNotFound)
+
+	dir = t.TempDir()
+	writeMsgFile(t, dir, "sample_msgs", "Loop", "Loop next\n")
+	assert.ErrorIs(t, LoadMessageDefinitions(logging.NewTestLogger(t), []string{dir}), ErrInvalidDefinition)
+}
+
+func TestLoadServiceDefinitions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sample_srvs", "srv")
+	assert.Nil(t, os.MkdirAll(dir, 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "SetMode.srv"), []byte("string mode\n---\nbool success\nstring message\n"), 0o644))
+	assert.Nil(t, LoadMessageDefinitions(logging.NewTestLogger(t), []string{dir}))
+
+	m, err := ConvertToRosMsg("sample_srvs/SetModeRequest", map[string]interface{}{"Mode": "auto"})
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, "auto", reflect.ValueOf(m).Elem().FieldByName("Mode").Interface(), "Mode should be auto")
+	_, err = GetMessageType("sample_srvs/SetModeResponse")
+	assert.Nil(t, err, "Error should be nil")
+}
+
+func TestSampleMessageRoundTrip(t *testing.T) {
+	m, err := sampleMessage("sensor_msgs/BatteryState")
+	assert.Nil(t, err, "Error should be nil")
+	assert.NotZero(t, reflect.ValueOf(m).Elem().FieldByName("CellVoltage").Len(), "Lists should be filled")
+	assert.NotZero(t, reflect.ValueOf(m).Elem().FieldByName("Header").FieldByName("Stamp").Interface(), "Nested fields should be filled")
+
+	r, err := convertFromRosMsg(m)
+	assert.Nil(t, err, "Error should be nil")
+	back, err := ConvertToRosMsg("sensor_msgs/BatteryState", r)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, m, back, "Message should survive a round trip")
+}
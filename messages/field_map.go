@@ -0,0 +1,152 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidFieldMap = errors.New("invalid field_map")
+
+// FieldMap renames fields between Viam readings and ROS messages. Paths are dot separated, eg:
+// "gps.lat" or "Header.FrameId", and can index into lists on the source side ("cells.0").
+type FieldMap struct {
+	// Fields maps a source path to a destination path
+	Fields map[string]string `json:"fields"`
+	// Defaults are set at a destination path when nothing was mapped there
+	Defaults map[string]interface{} `json:"defaults"`
+}
+
+func (f *FieldMap) Validate() error {
+	if f == nil {
+		return nil
+	}
+	targets := map[string]string{}
+	for from, to := range f.Fields {
+		if !validPath(from) || !validPath(to) {
+			return fmt.Errorf("%w: %q -> %q is not a valid mapping", ErrInvalidFieldMap, from, to)
+		}
+		if other, ok := targets[to]; ok {
+			return fmt.Errorf("%w: %q and %q both map to %q", ErrInvalidFieldMap, from, other, to)
+		}
+		targets[to] = from
+	}
+	for path := range f.Defaults {
+		if !validPath(path) {
+			return fmt.Errorf("%w: %q is not a valid default path", ErrInvalidFieldMap, path)
+		}
+	}
+	return nil
+}
+
+// Apply returns a copy of data with every mapped field moved to its destination and the
+// defaults filled in. Fields that are not mapped are kept where they are, including the siblings
+// of mapped nested fields. data itself is never modified.
+func (f *FieldMap) Apply(data map[string]interface{}) map[string]interface{} {
+	if f == nil || (len(f.Fields) == 0 && len(f.Defaults) == 0) {
+		return data
+	}
+
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	for from := range f.Fields {
+		deletePath(out, from)
+	}
+	for from, to := range f.Fields {
+		if v, ok := getPath(data, from); ok {
+			setPath(out, to, v)
+		}
+	}
+	for path, v := range f.Defaults {
+		if _, ok := getPath(out, path); !ok {
+			setPath(out, path, v)
+		}
+	}
+	return out
+}
+
+func validPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, seg := range strings.Split(path, ".") {
+		if seg == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func getPath(data map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = data
+	for _, seg := range strings.Split(path, ".") {
+		switch c := cur.(type) {
+		case map[string]interface{}:
+			v, ok := c[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			cur = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// deletePath removes path from data, copying the maps along it so values shared with the source
+// data are left untouched. Maps left empty are removed too. A path into a list removes the whole
+// list, since removing an element would move the ones after it.
+func deletePath(data map[string]interface{}, path string) {
+	seg, rest, nested := strings.Cut(path, ".")
+	v, ok := data[seg]
+	if !ok {
+		return
+	}
+	if !nested {
+		delete(data, seg)
+		return
+	}
+	switch c := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(c))
+		for k, v := range c {
+			copied[k] = v
+		}
+		deletePath(copied, rest)
+		if len(copied) == 0 {
+			delete(data, seg)
+		} else {
+			data[seg] = copied
+		}
+	case []interface{}:
+		delete(data, seg)
+	}
+}
+
+// setPath creates the intermediate maps as needed. Maps along the path are copied before
+// writing so values shared with the source data are left untouched.
+func setPath(data map[string]interface{}, path string, v interface{}) {
+	segs := strings.Split(path, ".")
+	cur := data
+	for _, seg := range segs[:len(segs)-1] {
+		next := map[string]interface{}{}
+		if existing, ok := cur[seg].(map[string]interface{}); ok {
+			for k, v := range existing {
+				next[k] = v
+			}
+		}
+		cur[seg] = next
+		cur = next
+	}
+	cur[segs[len(segs)-1]] = v
+}
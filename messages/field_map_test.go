@@ -0,0 +1,97 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldMapRenamesReadings(t *testing.T) {
+	fm := &FieldMap{
+		Fields: map[string]string{
+			"temp_c":   "Temperature",
+			"gps.lat":  "Latitude",
+			"gps.long": "Longitude",
+			"fix":      "Status.Status",
+		},
+		Defaults: map[string]interface{}{
+			"Header.FrameId":     "gps_link",
+			"PositionCovariance": []float64{1, 0, 0, 0, 1, 0, 0, 0, 1},
+			"Latitude":           0.0,
+		},
+	}
+	assert.Nil(t, fm.Validate())
+
+	readings := map[string]interface{}{
+		"gps":      map[string]interface{}{"lat": 40.7, "long": -74.0},
+		"fix":      2,
+		"Altitude": 12.0,
+	}
+	m, e := ConvertToRosMsg("sensor_msgs/NavSatFix", fm.Apply(readings))
+	assert.Nil(t, e, "Error should be nil")
+	fix := m.(*sensor_msgs.NavSatFix)
+	assert.Equal(t, 40.7, fix.Latitude, "Latitude should be mapped, not defaulted")
+	assert.Equal(t, -74.0, fix.Longitude, "Longitude should be mapped")
+	assert.Equal(t, int8(2), fix.Status.Status, "Status should be mapped")
+	assert.Equal(t, 12.0, fix.Altitude, "Unmapped keys should pass through")
+	assert.Equal(t, "gps_link", fix.Header.FrameId, "FrameId should be defaulted")
+	assert.Equal(t, 1.0, fix.PositionCovariance[8], "Covariance should be defaulted")
+
+	// the readings must not be modified
+	assert.Equal(t, map[string]interface{}{"lat": 40.7, "long": -74.0}, readings["gps"])
+}
+
+func TestFieldMapReverse(t *testing.T) {
+	fm := &FieldMap{Fields: map[string]string{"Data": "uptime_seconds", "Header.Stamp": "stamp"}}
+	r, e := convertFromRosMsg(&std_msgs.Int32{Data: 42})
+	assert.Nil(t, e, "Error should be nil")
+	out := fm.Apply(r)
+	assert.Equal(t, 42.0, out["uptime_seconds"], "Data should be renamed")
+	assert.NotContains(t, out, "Data", "Data should be removed")
+	assert.NotContains(t, out, "stamp", "Missing sources should be skipped")
+}
+
+func TestFieldMapListIndex(t *testing.T) {
+	fm := &FieldMap{Fields: map[string]string{"cells.1": "Data"}}
+	out := fm.Apply(map[string]interface{}{"cells": []interface{}{3.9, 4.1}})
+	assert.Equal(t, map[string]interface{}{"Data": 4.1}, out)
+}
+
+func TestFieldMapKeepsSiblings(t *testing.T) {
+	fm := &FieldMap{Fields: map[string]string{"imu.accel.x": "LinearAcceleration.X"}}
+	readings := map[string]interface{}{
+		"imu": map[string]interface{}{
+			"accel": map[string]interface{}{"x": 1.5, "y": 2.5},
+			"gyro":  map[string]interface{}{"z": 0.1},
+		},
+	}
+	out := fm.Apply(readings)
+	assert.Equal(t, map[string]interface{}{
+		"imu": map[string]interface{}{
+			"accel": map[string]interface{}{"y": 2.5},
+			"gyro":  map[string]interface{}{"z": 0.1},
+		},
+		"LinearAcceleration": map[string]interface{}{"X": 1.5},
+	}, out, "Should only move the mapped field")
+	assert.Equal(t, 1.5, readings["imu"].(map[string]interface{})["accel"].(map[string]interface{})["x"], "Should not modify the readings")
+
+	fm.Fields["imu.accel.y"] = "LinearAcceleration.Y"
+	fm.Fields["imu.gyro.z"] = "AngularVelocity.Z"
+	assert.NotContains(t, fm.Apply(readings), "imu", "Should drop maps left empty")
+}
+
+func TestFieldMapNil(t *testing.T) {
+	var fm *FieldMap
+	data := map[string]interface{}{"Data": 1}
+	assert.Nil(t, fm.Validate())
+	assert.Equal(t, data, fm.Apply(data))
+}
+
+func TestFieldMapValidate(t *testing.T) {
+	assert.ErrorIs(t, (&FieldMap{Fields: map[string]string{"a": ""}}).Validate(), ErrInvalidFieldMap)
+	assert.ErrorIs(t, (&FieldMap{Fields: map[string]string{"a..b": "Data"}}).Validate(), ErrInvalidFieldMap)
+	assert.ErrorIs(t, (&FieldMap{Fields: map[string]string{"a": "Data", "b": "Data"}}).Validate(), ErrInvalidFieldMap)
+	assert.ErrorIs(t, (&FieldMap{Defaults: map[string]interface{}{".": 1}}).Validate(), ErrInvalidFieldMap)
+}
@@ -0,0 +1,4 @@
+package messages
+
+// Compiles the ROS packages listed in $ROS_MESSAGE_PACKAGES into this package, see the README
+//go:generate go run .. generate -out .
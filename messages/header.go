@@ -0,0 +1,57 @@
+package messages
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+)
+
+// GetHeader returns the std_msgs/Header of the message msg points to, nil when it has none.
+func GetHeader(msg interface{}) *std_msgs.Header {
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	f := v.Elem().FieldByName("Header")
+	if !f.IsValid() || f.Type() != headerType {
+		return nil
+	}
+	return f.Addr().Interface().(*std_msgs.Header)
+}
+
+// FillHeader sets the parts of the message header that the readings left empty: the stamp,
+// the sequence number and the frame id. Messages without a header are left alone.
+func FillHeader(msg interface{}, stamp time.Time, seq uint32, frameId string) {
+	h := GetHeader(msg)
+	if h == nil {
+		return
+	}
+	if h.Stamp.IsZero() {
+		h.Stamp = stamp
+	}
+	if h.Seq == 0 {
+		h.Seq = seq
+	}
+	if h.FrameId == "" {
+		h.FrameId = frameId
+	}
+}
+
+// HeaderStamp returns the header stamp of converted readings, false when there is no header
+// or the stamp is zero.
+func HeaderStamp(data map[string]interface{}) (time.Time, bool) {
+	header, ok := data["Header"].(map[string]interface{})
+	if !ok {
+		return time.Time{}, false
+	}
+	s, ok := header["Stamp"].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || stamp.IsZero() {
+		return time.Time{}, false
+	}
+	return stamp, true
+}
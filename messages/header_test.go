@@ -0,0 +1,57 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFillHeader(t *testing.T) {
+	now := time.Now()
+	m, e := ConvertToRosMsg("ThrottlingStates", map[string]interface{}{"Throttled": true})
+	assert.Nil(t, e, "Error should be nil")
+	FillHeader(m, now, 7, "pi")
+	h := m.(*ThrottlingStates).Header
+	assert.Equal(t, now, h.Stamp, "Stamp should be filled")
+	assert.Equal(t, uint32(7), h.Seq, "Seq should be filled")
+	assert.Equal(t, "pi", h.FrameId, "FrameId should be filled")
+
+	stamp := time.Unix(10, 0).UTC()
+	m, e = ConvertToRosMsg("sensor_msgs/Imu", map[string]interface{}{
+		"Header": map[string]interface{}{"Stamp": stamp, "FrameId": "imu_link"},
+	})
+	assert.Nil(t, e, "Error should be nil")
+	FillHeader(m, now, 8, "pi")
+	h = m.(*sensor_msgs.Imu).Header
+	assert.Equal(t, stamp, h.Stamp, "Stamp from the readings should be kept")
+	assert.Equal(t, uint32(8), h.Seq, "Seq should be filled")
+	assert.Equal(t, "imu_link", h.FrameId, "FrameId from the readings should be kept")
+
+	i := &std_msgs.Int8{Data: 1}
+	FillHeader(i, now, 9, "pi")
+	assert.Equal(t, &std_msgs.Int8{Data: 1}, i, "Messages without a header should not change")
+	assert.Nil(t, GetHeader(i))
+	assert.Nil(t, GetHeader(nil))
+}
+
+func TestHeaderStamp(t *testing.T) {
+	stamp := time.Unix(1700000000, 123456789).UTC()
+	r, e := convertFromRosMsg(&sensor_msgs.Imu{Header: std_msgs.Header{Stamp: stamp}})
+	assert.Nil(t, e, "Error should be nil")
+	s, ok := HeaderStamp(r)
+	assert.True(t, ok, "Stamp should be found")
+	assert.True(t, stamp.Equal(s), "Stamp should be %v, got %v", stamp, s)
+
+	r, e = convertFromRosMsg(&sensor_msgs.Imu{})
+	assert.Nil(t, e, "Error should be nil")
+	_, ok = HeaderStamp(r)
+	assert.False(t, ok, "Zero stamps should be ignored")
+
+	r, e = convertFromRosMsg(&std_msgs.Int8{})
+	assert.Nil(t, e, "Error should be nil")
+	_, ok = HeaderStamp(r)
+	assert.False(t, ok, "Messages without a header have no stamp")
+}
@@ -1,43 +1,73 @@
 package messages
 
 import (
-	"encoding/json"
 	"errors"
-	"strings"
+	"fmt"
+	"time"
 
 	"github.com/bluenviron/goroslib/v2"
 	"go.viam.com/rdk/logging"
 )
 
 var ErrTypeNotFound = errors.New("type not found")
+var ErrChecksumMismatch = errors.New("message checksum mismatch")
 
-type TypeRegistry map[string]func() interface{}
-type SerializerTypeRegistry map[string]func(map[string]interface{}) interface{}
+// registry holds every type the bridge can publish or subscribe to
+var registry = NewTypeRegistry(
+	std_msgs_registry,
+	actionlib_msgs_registry,
+	diagnostic_msgs_registry,
+	geometry_msgs_registry,
+	nav_msgs_registry,
+	sensor_msgs_registry,
+	shape_msgs_registry,
+	stereo_msgs_registry,
+	trajectory_msgs_registry,
+	visualization_msgs_registry,
+	// custom types go last so they can replace any of the above
+	custom_type_registry,
+)
 
 type MessageHandler struct {
 	logger         logging.Logger
 	setLastMessage func(map[string]interface{})
+	aggregator     *aggregator
 }
 
 func NewMessageHandler(logger logging.Logger, setLastMessage func(map[string]interface{})) *MessageHandler {
 	return &MessageHandler{logger: logger, setLastMessage: setLastMessage}
 }
 
-func (h *MessageHandler) getCallback(typeName string) interface{} {
-	handler := h.handleMessage
-	if strings.HasPrefix(typeName, "std_msgs/") {
-		return GetStdMsgsCallback(handler, typeName)
-	} else {
-		return GetCustomMsgsCallback(handler, typeName)
+// Aggregate adds the aggregations of conf, over the messages handled so far, to every message
+// under AggregationsKey. A nil conf adds none.
+func (h *MessageHandler) Aggregate(conf *Aggregations) *MessageHandler {
+	h.aggregator = nil
+	if conf != nil {
+		h.aggregator = newAggregator(conf)
+	}
+	return h
+}
+
+// Aggregations returns the aggregations over the window ending at now, nil without any. A topic
+// that went quiet gets its values dropped as they leave the window, instead of reporting the
+// window of its last message forever.
+func (h *MessageHandler) Aggregations(now time.Time) map[string]interface{} {
+	if h.aggregator == nil {
+		return nil
 	}
+	return h.aggregator.at(now)
+}
+
+func (h *MessageHandler) getCallback(typeName string) (interface{}, error) {
+	return registry.Callback(typeName, h.handleMessage)
 }
 
 func (h *MessageHandler) GetSubscriberConfigWithHandler(typeName string) (*goroslib.SubscriberConf, error) {
-	handler := h.getCallback(typeName)
-	if handler != nil {
-		return &goroslib.SubscriberConf{Callback: handler}, nil
+	handler, err := h.getCallback(typeName)
+	if err != nil {
+		return nil, err
 	}
-	return nil, ErrTypeNotFound
+	return &goroslib.SubscriberConf{Callback: handler}, nil
 }
 
 func (h *MessageHandler) handleMessage(msg interface{}) error {
@@ -48,21 +78,34 @@ func (h *MessageHandler) handleMessage(msg interface{}) error {
 		return err
 	}
 	h.logger.Debugf("Converted message %#v", m)
+	if h.aggregator != nil {
+		m[AggregationsKey] = h.aggregator.add(time.Now(), m)
+	}
 	h.setLastMessage(m)
 	return nil
 }
 
-var type_registries = []TypeRegistry{std_msgs_registry, custom_type_registry}
-
 func GetMessageType(typeName string) (interface{}, error) {
-	for _, registry := range type_registries {
-		creator, ok := registry[typeName]
-		if ok {
-			return creator(), nil
-		}
-	}
+	return registry.New(typeName)
+}
 
-	return nil, ErrTypeNotFound
+// ResolveType returns the registered name of a type advertised by the master.
+func ResolveType(rosType string) (string, error) {
+	return registry.Resolve(rosType)
+}
+
+// CheckMD5 checks that the registered type has the checksum md5, sent by a publisher of the
+// remote type rosType. The error wraps ErrChecksumMismatch when the definitions differ.
+func CheckMD5(typeName string, rosType string, md5 string) error {
+	local, err := registry.MD5(typeName)
+	if err != nil {
+		return err
+	}
+	if local != md5 {
+		return fmt.Errorf("%w: the publisher sends %v with checksum %v, the registered %v has checksum %v",
+			ErrChecksumMismatch, rosType, md5, typeName, local)
+	}
+	return nil
 }
 
 func ConvertToRosMsg(typeName string, data map[string]interface{}) (interface{}, error) {
@@ -70,27 +113,20 @@ func ConvertToRosMsg(typeName string, data map[string]interface{}) (interface{},
 	if err != nil {
 		return nil, err
 	}
-	b, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
+	if decodeMessage(data, t) {
+		return t, nil
 	}
-	err = json.Unmarshal(b, &t)
+	// start over with encoding/json, it knows more types and gives the errors
+	t, err = GetMessageType(typeName)
 	if err != nil {
 		return nil, err
 	}
-	return t, err
+	return convertToRosMsgJSON(t, data)
 }
 
 func convertFromRosMsg(msg interface{}) (map[string]interface{}, error) {
-	data, err := json.Marshal(msg)
-	if err != nil {
-		return nil, err
-	}
-
-	var mapResult map[string]interface{}
-	err = json.Unmarshal(data, &mapResult)
-	if err != nil {
-		return nil, err
+	if data, ok := encodeMessage(msg); ok {
+		return data, nil
 	}
-	return mapResult, nil
+	return convertFromRosMsgJSON(msg)
 }
@@ -16,13 +16,53 @@ func TestGetCallback(t *testing.T) {
 		t.Logf("Received message: %#v", msg)
 	}
 	handler := NewMessageHandler(logger, setLastMessage)
-	f := handler.getCallback("std_msgs/Time")
+	f, err := handler.getCallback("std_msgs/Time")
+	assert.Nil(t, err, "Error should be nil")
 
 	// Check the return to ensure the function accepts only one parameter, and that parameter is *std_msgs/Time{}
 	assert.Equal(t, reflect.TypeOf(f).NumIn(), 1, "Function should accept only one parameter")
 	assert.Equal(t, reflect.TypeOf(f).In(0), reflect.TypeOf(&std_msgs.Time{}), "Parameter should be *std_msgs/Time{}")
 }
 
+func TestEveryTypeHasCallback(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	handler := NewMessageHandler(logger, func(msg map[string]interface{}) {})
+	for _, typeName := range registry.Types() {
+		m, err := GetMessageType(typeName)
+		assert.Nil(t, err, "Error should be nil for %v", typeName)
+		f, err := handler.getCallback(typeName)
+		assert.Nil(t, err, "Error should be nil for %v", typeName)
+		assert.Equal(t, reflect.TypeOf(m), reflect.TypeOf(f).In(0), "Callback should accept %T", m)
+	}
+}
+
+func TestUnknownTypeHasNoCallback(t *testing.T) {
+	handler := NewMessageHandler(logging.NewTestLogger(t), func(msg map[string]interface{}) {})
+	_, err := handler.GetSubscriberConfigWithHandler("std_msgs/DoesNotExist")
+	assert.ErrorIs(t, err, ErrTypeNotFound)
+}
+
+func TestResolveType(t *testing.T) {
+	typeName, err := ResolveType("std_msgs/Int32")
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, "std_msgs/Int32", typeName)
+	typeName, err = ResolveType("sample_msgs/ThrottlingStates")
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, "ThrottlingStates", typeName, "Should find types registered without their package")
+	_, err = ResolveType("std_msgs/DoesNotExist")
+	assert.ErrorIs(t, err, ErrTypeNotFound)
+}
+
+func TestCheckMD5(t *testing.T) {
+	// the checksums of std_msgs/Int32 and std_msgs/Float64 in ROS
+	assert.Nil(t, CheckMD5("std_msgs/Int32", "std_msgs/Int32", "da5909fbe378aeaf85e547e830cc1bb7"), "Error should be nil")
+	err := CheckMD5("std_msgs/Int32", "std_msgs/Float64", "fdb28210bfa9d7c91146260178d9a584")
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.ErrorContains(t, err, "fdb28210bfa9d7c91146260178d9a584")
+	assert.ErrorContains(t, err, "da5909fbe378aeaf85e547e830cc1bb7")
+	assert.ErrorIs(t, CheckMD5("std_msgs/DoesNotExist", "std_msgs/Int32", "da5909fbe378aeaf85e547e830cc1bb7"), ErrTypeNotFound)
+}
+
 func TestConvertStringReadings(t *testing.T) {
 	m, e := ConvertToRosMsg("std_msgs/String", map[string]interface{}{"Data": "Hello, World!"})
 	assert.Nil(t, e, "Error should be nil")
@@ -0,0 +1,79 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+var ErrInvalidNonFinite = errors.New("invalid non_finite_floats")
+
+// NonFinite is how NaN and ±Inf floats appear in readings.
+type NonFinite string
+
+const (
+	// NonFiniteNative keeps them as float64 values, the default
+	NonFiniteNative NonFinite = "native"
+	// NonFiniteNull replaces them with nil
+	NonFiniteNull NonFinite = "null"
+	// NonFiniteString replaces them with "NaN", "Infinity" or "-Infinity"
+	NonFiniteString NonFinite = "string"
+)
+
+func (n NonFinite) Validate() error {
+	switch n {
+	case "", NonFiniteNative, NonFiniteNull, NonFiniteString:
+		return nil
+	}
+	return fmt.Errorf("%w: %q, must be one of %q, %q or %q", ErrInvalidNonFinite, string(n), NonFiniteNative, NonFiniteNull, NonFiniteString)
+}
+
+// Apply replaces the non finite floats of data, in place, and returns it.
+func (n NonFinite) Apply(data map[string]interface{}) map[string]interface{} {
+	if n == "" || n == NonFiniteNative {
+		return data
+	}
+	for k, v := range data {
+		data[k] = n.replace(v)
+	}
+	return data
+}
+
+func (n NonFinite) replace(v interface{}) interface{} {
+	switch x := v.(type) {
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			if n == NonFiniteNull {
+				return nil
+			}
+			return nonFiniteString(x)
+		}
+	case map[string]interface{}:
+		return n.Apply(x)
+	case []interface{}:
+		for i, e := range x {
+			x[i] = n.replace(e)
+		}
+	}
+	return v
+}
+
+func nonFiniteString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case f > 0:
+		return "Infinity"
+	}
+	return "-Infinity"
+}
+
+// parseNonFinite reads the strings NaN and ±Inf can be given as, eg: "NaN", "Infinity" or "-Inf".
+func parseNonFinite(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || !(math.IsNaN(f) || math.IsInf(f, 0)) {
+		return 0, false
+	}
+	return f, true
+}
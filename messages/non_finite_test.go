@@ -0,0 +1,82 @@
+package messages
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertNonFiniteFromRosMsg(t *testing.T) {
+	r, e := convertFromRosMsg(&std_msgs.Float64{Data: math.NaN()})
+	assert.Nil(t, e, "Error should be nil")
+	assert.True(t, math.IsNaN(r["Data"].(float64)), "Data should be NaN")
+
+	r, e = convertFromRosMsg(&sensor_msgs.LaserScan{Ranges: []float32{1, float32(math.Inf(1)), float32(math.NaN())}})
+	assert.Nil(t, e, "Error should be nil")
+	ranges := r["Ranges"].([]interface{})
+	assert.Equal(t, math.Inf(1), ranges[1], "Inf should be kept")
+	assert.True(t, math.IsNaN(ranges[2].(float64)), "NaN should be kept")
+}
+
+func TestConvertNonFiniteToRosMsg(t *testing.T) {
+	for _, v := range []interface{}{math.NaN(), "NaN", "nan"} {
+		m, e := ConvertToRosMsg("std_msgs/Float32", map[string]interface{}{"Data": v})
+		assert.Nil(t, e, "Error should be nil for %#v", v)
+		assert.True(t, math.IsNaN(float64(m.(*std_msgs.Float32).Data)), "Data should be NaN for %#v", v)
+	}
+	for _, v := range []interface{}{math.Inf(-1), "-Infinity", "-Inf"} {
+		m, e := ConvertToRosMsg("std_msgs/Float64", map[string]interface{}{"Data": v})
+		assert.Nil(t, e, "Error should be nil for %#v", v)
+		assert.Equal(t, math.Inf(-1), m.(*std_msgs.Float64).Data, "Data should be -Inf for %#v", v)
+	}
+
+	m, e := ConvertToRosMsg("std_msgs/Int8", map[string]interface{}{"Data": 2, "Unknown": math.NaN()})
+	assert.Nil(t, e, "Dropped keys can be NaN")
+	assert.Equal(t, int8(2), m.(*std_msgs.Int8).Data)
+
+	_, e = ConvertToRosMsg("std_msgs/Float64", map[string]interface{}{"Data": "1.5"})
+	assert.NotNil(t, e, "Only non finite floats can be strings")
+	_, e = ConvertToRosMsg("std_msgs/Float64", map[string]interface{}{"Data": "1e400"})
+	assert.NotNil(t, e, "Out of range numbers are not infinite")
+	_, e = ConvertToRosMsg("std_msgs/Int32", map[string]interface{}{"Data": math.NaN()})
+	assert.NotNil(t, e, "Integers can't be NaN")
+}
+
+func TestNonFiniteApply(t *testing.T) {
+	readings := func() map[string]interface{} {
+		return map[string]interface{}{
+			"Data":   math.NaN(),
+			"Ranges": []interface{}{1.0, math.Inf(1), math.Inf(-1)},
+			"Nested": map[string]interface{}{"X": math.NaN(), "Y": 2.0},
+		}
+	}
+
+	r := NonFiniteNull.Apply(readings())
+	assert.Nil(t, r["Data"])
+	assert.Equal(t, []interface{}{1.0, nil, nil}, r["Ranges"])
+	assert.Equal(t, map[string]interface{}{"X": nil, "Y": 2.0}, r["Nested"])
+
+	r = NonFiniteString.Apply(readings())
+	assert.Equal(t, "NaN", r["Data"])
+	assert.Equal(t, []interface{}{1.0, "Infinity", "-Infinity"}, r["Ranges"])
+
+	r = NonFiniteNative.Apply(readings())
+	assert.True(t, math.IsNaN(r["Data"].(float64)))
+	r = NonFinite("").Apply(readings())
+	assert.True(t, math.IsNaN(r["Data"].(float64)))
+
+	// strings read back into the same values
+	m, e := ConvertToRosMsg("sensor_msgs/LaserScan", map[string]interface{}{"Ranges": NonFiniteString.Apply(readings())["Ranges"]})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, []float32{1, float32(math.Inf(1)), float32(math.Inf(-1))}, m.(*sensor_msgs.LaserScan).Ranges)
+}
+
+func TestNonFiniteValidate(t *testing.T) {
+	for _, n := range []NonFinite{"", NonFiniteNative, NonFiniteNull, NonFiniteString} {
+		assert.Nil(t, n.Validate())
+	}
+	assert.ErrorIs(t, NonFinite("zero").Validate(), ErrInvalidNonFinite)
+}
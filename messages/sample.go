@@ -0,0 +1,56 @@
+package messages
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// sampleMessage returns a new message of the given type with every field set to a distinct non
+// zero value, lists and strings included, for the round-trip tests written by generate.
+func sampleMessage(typeName string) (interface{}, error) {
+	m, err := registry.New(typeName)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	fillSample(reflect.ValueOf(m).Elem(), &n)
+	return m, nil
+}
+
+// fillSample sets v to a non zero value derived from the counter n, which it increments so
+// two fields never share a value.
+func fillSample(v reflect.Value, n *int) {
+	*n++
+	switch v.Type() {
+	case reflect.TypeOf(time.Time{}):
+		v.Set(reflect.ValueOf(time.Unix(int64(*n), int64(*n)).UTC()))
+		return
+	case reflect.TypeOf(time.Duration(0)):
+		v.SetInt(int64(time.Duration(*n) * time.Millisecond))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(*n % 100))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(*n % 100))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(*n) + 0.5)
+	case reflect.String:
+		v.SetString(fmt.Sprintf("sample %v", *n))
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fillSample(v.Index(i), n)
+		}
+	case reflect.Struct:
+		for _, f := range messageFields(v.Type()) {
+			fillSample(v.FieldByIndex(f.Index), n)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package messages
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msg"
+	"github.com/bluenviron/goroslib/v2/pkg/msgproc"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+)
+
+var ErrInvalidField = errors.New("invalid field")
+
+// FieldError is a strict conversion failure for a single field.
+type FieldError struct {
+	// Path is the dot separated path of the field in the readings, eg: Status.Service
+	Path string
+	// RosType is the type of the field in the message definition, eg: uint16
+	RosType string
+	Reason  string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%v (%v): %v", e.Path, e.RosType, e.Reason)
+}
+
+func (e *FieldError) Unwrap() error {
+	return ErrInvalidField
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	headerType   = reflect.TypeOf(std_msgs.Header{})
+)
+
+// ConvertToRosMsgStrict is ConvertToRosMsg, except that data must match the message exactly:
+// unknown keys, missing fields and values that don't fit the field's type are errors. Headers
+// are optional. All the problems found are returned, each one as a *FieldError.
+func ConvertToRosMsgStrict(typeName string, data map[string]interface{}) (interface{}, error) {
+	t, err := GetMessageType(typeName)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	checkStruct(reflect.TypeOf(t).Elem(), data, "", &errs)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return ConvertToRosMsg(typeName, data)
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// messageFields returns the data fields of a message, without the msg.Package style markers.
+func messageFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			switch f.Type {
+			case reflect.TypeOf(msg.Package(0)), reflect.TypeOf(msg.Name(0)), reflect.TypeOf(msg.Definitions(0)):
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func checkStruct(t reflect.Type, data map[string]interface{}, path string, errs *[]error) {
+	fields := messageFields(t)
+	known := map[string]bool{}
+	for _, f := range fields {
+		known[f.Name] = true
+		v, ok := data[f.Name]
+		if !ok {
+			if f.Type != headerType {
+				*errs = append(*errs, &FieldError{Path: joinPath(path, f.Name), RosType: rosTypeName(f.Type, f.Tag), Reason: "missing"})
+			}
+			continue
+		}
+		checkValue(f.Type, f.Tag, v, joinPath(path, f.Name), errs)
+	}
+
+	var unknown []string
+	for k := range data {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		*errs = append(*errs, &FieldError{Path: joinPath(path, k), RosType: "none", Reason: "unknown field"})
+	}
+}
+
+func checkValue(t reflect.Type, tag reflect.StructTag, v interface{}, path string, errs *[]error) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, &FieldError{Path: path, RosType: rosTypeName(t, tag), Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if v == nil {
+		fail("is null")
+		return
+	}
+	rv := reflect.ValueOf(v)
+	// Values that already have the right Go type are always fine
+	if rv.Type() == t {
+		return
+	}
+
+	switch {
+	case t == timeType:
+		if s, ok := v.(string); ok {
+			if _, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return
+			}
+		}
+		fail("expected a time, got %T", v)
+		return
+	case t == durationType:
+		checkInteger(t, rv, fail)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		fail("expected a bool, got %T", v)
+	case reflect.String:
+		fail("expected a string, got %T", v)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		checkInteger(t, rv, fail)
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(rv)
+		if !ok {
+			fail("expected a number, got %T", v)
+			return
+		}
+		if t.Kind() == reflect.Float32 && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+			fail("%v overflows float32", f)
+		}
+	case reflect.Slice, reflect.Array:
+		// byte arrays can also be given base64 encoded, like encoding/json expects
+		if s, ok := v.(string); ok && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+				fail("invalid base64: %v", err)
+			}
+			return
+		}
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			fail("expected a list, got %T", v)
+			return
+		}
+		if t.Kind() == reflect.Array && rv.Len() != t.Len() {
+			fail("expected %v elements, got %v", t.Len(), rv.Len())
+			return
+		}
+		for i := 0; i < rv.Len(); i++ {
+			checkValue(t.Elem(), tag, rv.Index(i).Interface(), fmt.Sprintf("%v.%v", path, i), errs)
+		}
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			fail("expected an object, got %T", v)
+			return
+		}
+		checkStruct(t, m, path, errs)
+	default:
+		fail("unsupported field type")
+	}
+}
+
+func checkInteger(t reflect.Type, rv reflect.Value, fail func(string, ...interface{})) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		if isUnsigned(t) {
+			if i < 0 || uint64(i) > maxUint(t) {
+				fail("%v overflows %v", i, t.Kind())
+			}
+		} else if i < minInt(t) || i > maxInt(t) {
+			fail("%v overflows %v", i, t.Kind())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if isUnsigned(t) {
+			if u > maxUint(t) {
+				fail("%v overflows %v", u, t.Kind())
+			}
+		} else if u > uint64(maxInt(t)) {
+			fail("%v overflows %v", u, t.Kind())
+		}
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) || f != math.Trunc(f) {
+			fail("%v is not an integer", f)
+			return
+		}
+		// float64(maxInt) rounds up, so the upper bounds are exclusive
+		if isUnsigned(t) {
+			if f < 0 || f >= float64(maxUint(t))+1 {
+				fail("%v overflows %v", f, t.Kind())
+			}
+		} else if f < float64(minInt(t)) || f >= float64(maxInt(t))+1 {
+			fail("%v overflows %v", f, t.Kind())
+		}
+	case reflect.String:
+		// 64-bit integers can be given as decimal strings, any integer as a json.Number
+		if _, ok := rv.Interface().(json.Number); !ok && t.Bits() != 64 {
+			fail("expected an integer, got %v", rv.Type())
+			return
+		}
+		s := rv.String()
+		if isUnsigned(t) {
+			if u, err := strconv.ParseUint(s, 10, 64); err != nil || u > maxUint(t) {
+				fail("%q is not a valid %v", s, t.Kind())
+			}
+		} else if i, err := strconv.ParseInt(s, 10, 64); err != nil || i < minInt(t) || i > maxInt(t) {
+			fail("%q is not a valid %v", s, t.Kind())
+		}
+	default:
+		fail("expected an integer, got %v", rv.Type())
+	}
+}
+
+func isUnsigned(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func maxUint(t reflect.Type) uint64 {
+	return math.MaxUint64 >> (64 - t.Bits())
+}
+
+func maxInt(t reflect.Type) int64 {
+	return math.MaxInt64 >> (64 - t.Bits())
+}
+
+func minInt(t reflect.Type) int64 {
+	return math.MinInt64 >> (64 - t.Bits())
+}
+
+func toFloat(rv reflect.Value) (float64, bool) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	switch x := rv.Interface().(type) {
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case string:
+		return parseNonFinite(x)
+	}
+	return 0, false
+}
+
+// rosTypeName returns the type of a field as it is written in a .msg file.
+func rosTypeName(t reflect.Type, tag reflect.StructTag) string {
+	if rosType := tag.Get("rostype"); rosType != "" && (t.Kind() == reflect.Int8 || t.Kind() == reflect.Uint8) {
+		return rosType
+	}
+	switch {
+	case t == timeType:
+		return "time"
+	case t == durationType:
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		return rosTypeName(t.Elem(), tag) + "[]"
+	case reflect.Array:
+		return fmt.Sprintf("%v[%v]", rosTypeName(t.Elem(), tag), t.Len())
+	case reflect.Struct:
+		name, err := msgproc.Type(reflect.Zero(t).Interface())
+		if err != nil {
+			return t.String()
+		}
+		return name
+	}
+	return strings.ToLower(t.Kind().String())
+}
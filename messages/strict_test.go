@@ -0,0 +1,120 @@
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+	"github.com/stretchr/testify/assert"
+)
+
+func fieldErrors(t *testing.T, err error) []*FieldError {
+	assert.ErrorIs(t, err, ErrInvalidField)
+	var out []*FieldError
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fe *FieldError
+		assert.True(t, errors.As(e, &fe), "Error should be a FieldError")
+		out = append(out, fe)
+	}
+	return out
+}
+
+func TestStrictAcceptsExactReadings(t *testing.T) {
+	m, e := ConvertToRosMsgStrict("std_msgs/UInt8", map[string]interface{}{"Data": 255})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, uint8(255), m.(*std_msgs.UInt8).Data, "Data should be 255")
+
+	m, e = ConvertToRosMsgStrict("std_msgs/Int8", map[string]interface{}{"Data": -128.0})
+	assert.Nil(t, e, "Error should be nil")
+	assert.Equal(t, int8(-128), m.(*std_msgs.Int8).Data, "Data should be -128")
+
+	for _, v := range []interface{}{math.NaN(), math.Inf(1), "-Infinity"} {
+		_, e = ConvertToRosMsgStrict("std_msgs/Float32", map[string]interface{}{"Data": v})
+		assert.Nil(t, e, "Error should be nil for %#v", v)
+	}
+	_, e = ConvertToRosMsgStrict("std_msgs/Float32", map[string]interface{}{"Data": "1.5"})
+	assert.ErrorIs(t, e, ErrInvalidField)
+}
+
+func TestStrictOverflow(t *testing.T) {
+	_, e := ConvertToRosMsgStrict("std_msgs/UInt8", map[string]interface{}{"Data": 300})
+	errs := fieldErrors(t, e)
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, "Data", errs[0].Path)
+	assert.Equal(t, "uint8", errs[0].RosType)
+	assert.Equal(t, "Data (uint8): 300 overflows uint8", errs[0].Error())
+
+	for typeName, v := range map[string]interface{}{
+		"std_msgs/UInt16":  -1,
+		"std_msgs/Int16":   40000.0,
+		"std_msgs/Int32":   1.5,
+		"std_msgs/Float32": 1e39,
+		"std_msgs/Char":    uint64(256),
+	} {
+		_, e := ConvertToRosMsgStrict(typeName, map[string]interface{}{"Data": v})
+		assert.ErrorIs(t, e, ErrInvalidField, "%v should not accept %v", typeName, v)
+	}
+}
+
+func TestStrict64BitIntegers(t *testing.T) {
+	for typeName, v := range map[string]interface{}{
+		"std_msgs/Int64":    "-9007199254740993",
+		"std_msgs/UInt64":   "18446744073709551615",
+		"std_msgs/Duration": "1500",
+		"std_msgs/Int8":     json.Number("-3"),
+		"std_msgs/Float32":  json.Number("0.5"),
+	} {
+		_, e := ConvertToRosMsgStrict(typeName, map[string]interface{}{"Data": v})
+		assert.Nil(t, e, "%v should accept %#v", typeName, v)
+	}
+
+	_, e := ConvertToRosMsgStrict("std_msgs/Int32", map[string]interface{}{"Data": "5"})
+	errs := fieldErrors(t, e)
+	assert.Equal(t, "Data (int32): expected an integer, got string", errs[0].Error())
+	_, e = ConvertToRosMsgStrict("std_msgs/UInt64", map[string]interface{}{"Data": "-1"})
+	errs = fieldErrors(t, e)
+	assert.Equal(t, "Data (uint64): \"-1\" is not a valid uint64", errs[0].Error())
+}
+
+func TestStrictUnknownAndMissing(t *testing.T) {
+	_, e := ConvertToRosMsgStrict("std_msgs/ColorRGBA", map[string]interface{}{"R": 1.0, "G": 0.0, "B": 0.0, "Alpha": 1.0})
+	errs := fieldErrors(t, e)
+	assert.Equal(t, 2, len(errs))
+	assert.Equal(t, "A (float32): missing", errs[0].Error())
+	assert.Equal(t, "Alpha (none): unknown field", errs[1].Error())
+}
+
+func TestStrictNestedPaths(t *testing.T) {
+	_, e := ConvertToRosMsgStrict("sensor_msgs/NavSatFix", map[string]interface{}{
+		"Status":                 map[string]interface{}{"Status": 0, "Service": 70000},
+		"Latitude":               1.0,
+		"Longitude":              "east",
+		"Altitude":               0.0,
+		"PositionCovariance":     []float64{0, 0, 0},
+		"PositionCovarianceType": 0,
+	})
+	errs := fieldErrors(t, e)
+	assert.Equal(t, 3, len(errs))
+	assert.Equal(t, "Status.Service (uint16): 70000 overflows uint16", errs[0].Error())
+	assert.Equal(t, "Longitude (float64): expected a number, got string", errs[1].Error())
+	assert.Equal(t, "PositionCovariance (float64[9]): expected 9 elements, got 3", errs[2].Error())
+}
+
+func TestStrictListElements(t *testing.T) {
+	_, e := ConvertToRosMsgStrict("sensor_msgs/BatteryState", map[string]interface{}{
+		"Voltage": 1.0, "Temperature": 1.0, "Current": 1.0, "Charge": 1.0, "Capacity": 1.0,
+		"DesignCapacity": 1.0, "Percentage": 1.0, "PowerSupplyStatus": 0, "PowerSupplyHealth": 0,
+		"PowerSupplyTechnology": 0, "Present": true, "CellVoltage": []interface{}{4.0, "x"},
+		"CellTemperature": []interface{}{}, "Location": "", "SerialNumber": "",
+	})
+	errs := fieldErrors(t, e)
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, "CellVoltage.1 (float32): expected a number, got string", errs[0].Error())
+
+	m, e := ConvertToRosMsgStrict("sensor_msgs/Temperature", map[string]interface{}{"Temperature": 21.5, "Variance": 0})
+	assert.Nil(t, e, "Header should be optional")
+	assert.Equal(t, 21.5, m.(*sensor_msgs.Temperature).Temperature)
+}
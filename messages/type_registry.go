@@ -0,0 +1,113 @@
+package messages
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgproc"
+)
+
+// Registrations maps a ROS type name to a prototype of the Go struct used for it.
+type Registrations map[string]interface{}
+
+// TypeRegistry is the single place message types are looked up, for publishing and subscribing.
+// The typed subscriber callbacks goroslib needs are built by reflection from the registered type.
+type TypeRegistry struct {
+	mu    sync.RWMutex
+	types map[string]reflect.Type
+}
+
+func NewTypeRegistry(registrations ...Registrations) *TypeRegistry {
+	r := &TypeRegistry{types: map[string]reflect.Type{}}
+	r.RegisterAll(registrations...)
+	return r
+}
+
+// RegisterAll adds every type of the registrations, later ones replace earlier ones.
+func (r *TypeRegistry) RegisterAll(registrations ...Registrations) {
+	for _, reg := range registrations {
+		for typeName, prototype := range reg {
+			r.Register(typeName, prototype)
+		}
+	}
+}
+
+// Register adds or replaces a type. The prototype can be a struct or a pointer to one.
+func (r *TypeRegistry) Register(typeName string, prototype interface{}) {
+	t := reflect.TypeOf(prototype)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[typeName] = t
+}
+
+func (r *TypeRegistry) lookup(typeName string) (reflect.Type, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.types[typeName]
+	if !ok {
+		return nil, ErrTypeNotFound
+	}
+	return t, nil
+}
+
+// New returns a pointer to a new zero value message of the given type.
+func (r *TypeRegistry) New(typeName string) (interface{}, error) {
+	t, err := r.lookup(typeName)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.New(t).Interface(), nil
+}
+
+// Resolve returns the registered name of a ROS type, as the master advertises it. Types
+// registered without their package, like the custom ones, are found by their bare name.
+func (r *TypeRegistry) Resolve(rosType string) (string, error) {
+	if _, err := r.lookup(rosType); err == nil {
+		return rosType, nil
+	}
+	if i := strings.LastIndex(rosType, "/"); i >= 0 {
+		if _, err := r.lookup(rosType[i+1:]); err == nil {
+			return rosType[i+1:], nil
+		}
+	}
+	return "", ErrTypeNotFound
+}
+
+// MD5 returns the checksum ROS computes from the definition of the given type.
+func (r *TypeRegistry) MD5(typeName string) (string, error) {
+	t, err := r.lookup(typeName)
+	if err != nil {
+		return "", err
+	}
+	return msgproc.MD5(reflect.New(t).Elem().Interface())
+}
+
+// Callback returns a func(*T) for the given type that passes every message to handleMessage.
+func (r *TypeRegistry) Callback(typeName string, handleMessage func(interface{}) error) (interface{}, error) {
+	t, err := r.lookup(typeName)
+	if err != nil {
+		return nil, err
+	}
+	fnType := reflect.FuncOf([]reflect.Type{reflect.PointerTo(t)}, nil, false)
+	return reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		handleMessage(args[0].Interface())
+		return nil
+	}).Interface(), nil
+}
+
+// Types returns the sorted names of all registered types.
+func (r *TypeRegistry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.types))
+	for name := range r.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 
 	"go.viam.com/rdk/components/generic"
 	"go.viam.com/rdk/components/sensor"
@@ -9,12 +11,21 @@ import (
 	"go.viam.com/rdk/module"
 	"go.viam.com/utils"
 
+	"github.com/viam-soleng/viam-ros-sensor-bridge/generator"
 	"github.com/viam-soleng/viam-ros-sensor-bridge/ros_sensor_publisher"
 	"github.com/viam-soleng/viam-ros-sensor-bridge/ros_sensor_subscriber"
 	module_utils "github.com/viam-soleng/viam-ros-sensor-bridge/utils"
 )
 
 func main() {
+	// The generate subcommand runs standalone, without starting the module
+	if len(os.Args) > 1 && os.Args[1] == generator.Command {
+		if err := generator.Run(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	utils.ContextualMain(mainWithArgs, module.NewLoggerFromArgs(module_utils.LoggerName))
 }
 
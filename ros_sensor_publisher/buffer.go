@@ -0,0 +1,438 @@
+package ros_sensor_publisher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/protocommon"
+)
+
+// replaySettle is how long a new publisher waits before replaying the buffer, subscribers need
+// a moment to connect to it and ROS doesn't queue messages for them meanwhile
+const replaySettle = time.Second
+
+const defaultBufferMessages = 1000
+
+const (
+	bufferMemory = "memory"
+	bufferDisk   = "disk"
+	dropOldest   = "oldest"
+	dropNewest   = "newest"
+)
+
+// BufferConfig is the buffer of messages published while the master is unreachable.
+type BufferConfig struct {
+	// Storage is memory, the default, or disk, under VIAM_MODULE_DATA
+	Storage string `json:"storage"`
+	// MaxMessages is the number of messages the buffer holds, 1000 by default
+	MaxMessages int `json:"max_messages"`
+	// MaxAgeSecs drops messages older than that, 0 keeps them
+	MaxAgeSecs float64 `json:"max_age_secs"`
+	// Drop is which message is dropped when the buffer is full: oldest, the default, or newest
+	Drop string `json:"drop"`
+}
+
+func (c *BufferConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	switch c.Storage {
+	case "", bufferMemory, bufferDisk:
+	default:
+		return fmt.Errorf("buffer storage must be %q or %q", bufferMemory, bufferDisk)
+	}
+	switch c.Drop {
+	case "", dropOldest, dropNewest:
+	default:
+		return fmt.Errorf("buffer drop must be %q or %q", dropOldest, dropNewest)
+	}
+	if c.MaxMessages < 0 {
+		return errors.New("buffer max messages must be a positive number")
+	}
+	if c.MaxAgeSecs < 0 || math.IsInf(c.MaxAgeSecs, 0) || math.IsNaN(c.MaxAgeSecs) {
+		return errors.New("buffer max age must be a positive number of seconds")
+	}
+	return nil
+}
+
+// store holds buffered messages, oldest first.
+type store interface {
+	push(msg interface{}, at time.Time) error
+	// front returns the time of the oldest message
+	front() (time.Time, bool)
+	// pop removes the oldest message and returns it
+	pop() (interface{}, error)
+	// discard removes the oldest message
+	discard() error
+	len() int
+	close() error
+}
+
+// sampleBuffer applies the limits of a BufferConfig to a store.
+type sampleBuffer struct {
+	store       store
+	maxMessages int
+	maxAge      time.Duration
+	dropNewest  bool
+	dropped     int64
+}
+
+// newSampleBuffer opens the buffer of a sensor. Disk buffers are kept in a file per sensor, topic
+// and message type, and messages left there by a previous run are replayed too.
+func newSampleBuffer(conf *BufferConfig, sensor *SensorConfig, newMsg func() (interface{}, error)) (*sampleBuffer, error) {
+	b := &sampleBuffer{
+		maxMessages: conf.MaxMessages,
+		maxAge:      time.Duration(conf.MaxAgeSecs * float64(time.Second)),
+		dropNewest:  conf.Drop == dropNewest,
+	}
+	if b.maxMessages == 0 {
+		b.maxMessages = defaultBufferMessages
+	}
+	if conf.Storage != bufferDisk {
+		b.store = &memoryStore{}
+		return b, nil
+	}
+
+	dir := os.Getenv("VIAM_MODULE_DATA")
+	if dir == "" {
+		return nil, errors.New("VIAM_MODULE_DATA is not set, can't buffer on disk")
+	}
+	dir = filepath.Join(dir, "buffers")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := unsafeFileChars.ReplaceAllString(sensor.Name+"_"+sensor.Topic+"_"+sensor.Type, "_") + ".buf"
+	s, err := openDiskStore(filepath.Join(dir, name), newMsg)
+	if err != nil {
+		return nil, err
+	}
+	b.store = s
+	// the limits may have changed since the messages were buffered
+	for s.len() > b.maxMessages {
+		if err := s.discard(); err != nil {
+			s.close()
+			return nil, err
+		}
+		b.dropped++
+	}
+	return b, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// push adds a message read at at, dropping expired messages and one message if the buffer is full.
+func (b *sampleBuffer) push(msg interface{}, at time.Time) error {
+	b.expire(at)
+	if b.store.len() >= b.maxMessages {
+		b.dropped++
+		if b.dropNewest {
+			return nil
+		}
+		if err := b.store.discard(); err != nil {
+			return err
+		}
+	}
+	return b.store.push(msg, at)
+}
+
+// replay writes the messages that haven't expired, oldest first, and empties the buffer.
+func (b *sampleBuffer) replay(now time.Time, write func(msg interface{})) (int, error) {
+	b.expire(now)
+	n := 0
+	var errs []error
+	for b.store.len() > 0 {
+		msg, err := b.store.pop()
+		if err != nil {
+			b.dropped++
+			errs = append(errs, err)
+			continue
+		}
+		write(msg)
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+func (b *sampleBuffer) expire(now time.Time) {
+	if b.maxAge == 0 {
+		return
+	}
+	for {
+		at, ok := b.store.front()
+		if !ok || now.Sub(at) <= b.maxAge {
+			return
+		}
+		if b.store.discard() != nil {
+			return
+		}
+		b.dropped++
+	}
+}
+
+type bufferedMessage struct {
+	msg interface{}
+	at  time.Time
+}
+
+type memoryStore struct {
+	messages []bufferedMessage
+}
+
+func (s *memoryStore) push(msg interface{}, at time.Time) error {
+	s.messages = append(s.messages, bufferedMessage{msg, at})
+	return nil
+}
+
+func (s *memoryStore) front() (time.Time, bool) {
+	if len(s.messages) == 0 {
+		return time.Time{}, false
+	}
+	return s.messages[0].at, true
+}
+
+func (s *memoryStore) pop() (interface{}, error) {
+	msg := s.messages[0].msg
+	s.messages[0] = bufferedMessage{}
+	s.messages = s.messages[1:]
+	return msg, nil
+}
+
+func (s *memoryStore) discard() error {
+	_, err := s.pop()
+	return err
+}
+
+func (s *memoryStore) len() int {
+	return len(s.messages)
+}
+
+func (s *memoryStore) close() error {
+	return nil
+}
+
+// recordHeaderSize is the size of the header of a disk record: the size of the message and the
+// time it was read, in Unix nanoseconds
+const recordHeaderSize = 12
+
+// fileHeaderSize is the size of the header of a disk buffer: the offset of the oldest record still
+// buffered, so the records dropped or replayed before a restart aren't replayed again
+const fileHeaderSize = 8
+
+// compactBytes is the space dropped messages can take at the start of the file before it is
+// rewritten
+const compactBytes = 1 << 20
+
+type diskRecord struct {
+	offset int64
+	size   uint32
+	at     time.Time
+}
+
+// diskStore appends messages, in the ROS wire format, to a file. The records of the messages
+// still buffered are kept in memory.
+type diskStore struct {
+	path    string
+	f       *os.File
+	newMsg  func() (interface{}, error)
+	records []diskRecord
+	head    int
+	end     int64
+}
+
+func openDiskStore(path string, newMsg func() (interface{}, error)) (*diskStore, error) {
+	// a compaction that didn't get to replace the file left the previous one intact
+	if err := os.Remove(path + ".tmp"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &diskStore{path: path, f: f, newMsg: newMsg, end: fileHeaderSize}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// index the records a previous run left from the persisted head, a torn record at the end is
+	// cut off
+	header := make([]byte, recordHeaderSize)
+	if _, err := f.ReadAt(header[:fileHeaderSize], 0); err == nil {
+		if head := int64(binary.LittleEndian.Uint64(header)); head >= fileHeaderSize && head <= info.Size() {
+			s.end = head
+		}
+	}
+	for {
+		if _, err := f.ReadAt(header, s.end); err != nil {
+			break
+		}
+		r := diskRecord{
+			offset: s.end,
+			size:   binary.LittleEndian.Uint32(header),
+			at:     time.Unix(0, int64(binary.LittleEndian.Uint64(header[4:]))),
+		}
+		if r.offset+recordHeaderSize+int64(r.size) > info.Size() {
+			break
+		}
+		s.records = append(s.records, r)
+		s.end = r.offset + recordHeaderSize + int64(r.size)
+	}
+	if len(s.records) == 0 {
+		s.end = fileHeaderSize
+	}
+	err = f.Truncate(s.end)
+	if err == nil {
+		err = s.writeHead()
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeHead persists the offset of the oldest record still buffered.
+func (s *diskStore) writeHead() error {
+	head := s.end
+	if s.len() > 0 {
+		head = s.records[s.head].offset
+	}
+	header := make([]byte, fileHeaderSize)
+	binary.LittleEndian.PutUint64(header, uint64(head))
+	_, err := s.f.WriteAt(header, 0)
+	return err
+}
+
+func (s *diskStore) push(msg interface{}, at time.Time) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, recordHeaderSize))
+	if err := protocommon.MessageEncode(&buf, msg); err != nil {
+		return err
+	}
+	record := buf.Bytes()
+	size := uint32(len(record) - recordHeaderSize)
+	binary.LittleEndian.PutUint32(record, size)
+	binary.LittleEndian.PutUint64(record[4:], uint64(at.UnixNano()))
+	if _, err := s.f.WriteAt(record, s.end); err != nil {
+		return err
+	}
+	s.records = append(s.records, diskRecord{offset: s.end, size: size, at: at})
+	s.end += int64(len(record))
+	return nil
+}
+
+func (s *diskStore) front() (time.Time, bool) {
+	if s.len() == 0 {
+		return time.Time{}, false
+	}
+	return s.records[s.head].at, true
+}
+
+func (s *diskStore) pop() (interface{}, error) {
+	r := s.records[s.head]
+	buf := make([]byte, r.size)
+	if _, err := s.f.ReadAt(buf, r.offset+recordHeaderSize); err != nil && !(errors.Is(err, io.EOF) && r.size == 0) {
+		return nil, err
+	}
+	// discarding can compact the file, so the record is read first
+	if err := s.discard(); err != nil {
+		return nil, err
+	}
+	msg, err := s.newMsg()
+	if err != nil {
+		return nil, err
+	}
+	if err := protocommon.MessageDecode(bytes.NewReader(buf), msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// discard drops the oldest record, the file is emptied with the buffer and compacted when the
+// dropped records take too much of it.
+func (s *diskStore) discard() error {
+	s.head++
+	if s.head == len(s.records) {
+		// truncated first, a head past the end of the file is read as an empty buffer
+		s.records, s.head, s.end = nil, 0, fileHeaderSize
+		if err := s.f.Truncate(s.end); err != nil {
+			return err
+		}
+		return s.writeHead()
+	}
+	if err := s.writeHead(); err != nil {
+		return err
+	}
+	if start := s.records[s.head].offset; start > compactBytes && start-fileHeaderSize > s.end-start {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact writes the records still buffered to a new file that replaces the buffer, so a crash
+// leaves either the old or the new file.
+func (s *diskStore) compact() error {
+	start := s.records[s.head].offset
+	buf := make([]byte, fileHeaderSize+s.end-start)
+	binary.LittleEndian.PutUint64(buf, fileHeaderSize)
+	if _, err := s.f.ReadAt(buf[fileHeaderSize:], start); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := writeFileSync(tmp, buf); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	s.f.Close()
+	s.f = f
+
+	records := make([]diskRecord, 0, len(s.records)-s.head)
+	for _, r := range s.records[s.head:] {
+		r.offset -= start - fileHeaderSize
+		records = append(records, r)
+	}
+	s.records, s.head, s.end = records, 0, int64(len(buf))
+	return nil
+}
+
+// writeFileSync writes data to a new file at path and syncs it to the disk.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *diskStore) len() int {
+	return len(s.records) - s.head
+}
+
+func (s *diskStore) close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,195 @@
+package ros_sensor_publisher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+	"github.com/stretchr/testify/assert"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/messages"
+	"github.com/viam-soleng/viam-ros-sensor-bridge/utils"
+)
+
+func replayed(t *testing.T, b *sampleBuffer, now time.Time) []int32 {
+	out := []int32{}
+	_, err := b.replay(now, func(msg interface{}) {
+		out = append(out, msg.(*std_msgs.Int32).Data)
+	})
+	assert.Nil(t, err, "Error should be nil")
+	return out
+}
+
+func TestSampleBuffer(t *testing.T) {
+	sensorConf := &SensorConfig{Topic: "/uptime", Type: "std_msgs/Int32", Name: "uptime"}
+	start := time.Now()
+	push := func(b *sampleBuffer, values ...int32) {
+		for i, v := range values {
+			assert.Nil(t, b.push(&std_msgs.Int32{Data: v}, start.Add(time.Duration(i)*time.Second)), "Error should be nil")
+		}
+	}
+
+	b, err := newSampleBuffer(&BufferConfig{MaxMessages: 3}, sensorConf, nil)
+	assert.Nil(t, err, "Error should be nil")
+	push(b, 1, 2, 3, 4, 5)
+	assert.Equal(t, []int32{3, 4, 5}, replayed(t, b, start), "Should drop the oldest messages")
+	assert.EqualValues(t, 2, b.dropped)
+	assert.Empty(t, replayed(t, b, start), "Should empty the buffer")
+
+	b, err = newSampleBuffer(&BufferConfig{MaxMessages: 3, Drop: dropNewest}, sensorConf, nil)
+	assert.Nil(t, err, "Error should be nil")
+	push(b, 1, 2, 3, 4, 5)
+	assert.Equal(t, []int32{1, 2, 3}, replayed(t, b, start), "Should drop the newest messages")
+
+	b, err = newSampleBuffer(&BufferConfig{MaxAgeSecs: 2.5}, sensorConf, nil)
+	assert.Nil(t, err, "Error should be nil")
+	push(b, 1, 2, 3, 4, 5)
+	assert.Equal(t, []int32{4, 5}, replayed(t, b, start.Add(5*time.Second)), "Should drop the expired messages")
+}
+
+func TestDiskBuffer(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("VIAM_MODULE_DATA", dir)
+	sensorConf := &SensorConfig{Topic: "/uptime", Type: "std_msgs/Int32", Name: "uptime"}
+	conf := &BufferConfig{Storage: bufferDisk, MaxMessages: 3}
+	newMsg := func() (interface{}, error) { return messages.GetMessageType(sensorConf.Type) }
+	start := time.Now()
+
+	b, err := newSampleBuffer(conf, sensorConf, newMsg)
+	assert.Nil(t, err, "Error should be nil")
+	for i, v := range []int32{1, 2, 3, 4} {
+		assert.Nil(t, b.push(&std_msgs.Int32{Data: v}, start.Add(time.Duration(i)*time.Second)), "Error should be nil")
+	}
+	assert.Nil(t, b.store.close(), "Error should be nil")
+
+	// a torn record at the end is ignored
+	path := filepath.Join(dir, "buffers", "uptime__uptime_std_msgs_Int32.buf")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	assert.Nil(t, err, "Error should be nil")
+	_, err = f.Write([]byte{4, 0, 0})
+	assert.Nil(t, err, "Error should be nil")
+	f.Close()
+
+	b, err = newSampleBuffer(conf, sensorConf, newMsg)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, 3, b.store.len(), "Should reload the messages of the previous run")
+	at, ok := b.store.front()
+	assert.True(t, ok)
+	assert.True(t, start.Add(time.Second).Equal(at), "Should keep the time messages were read")
+	assert.Equal(t, []int32{2, 3, 4}, replayed(t, b, start))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err, "Error should be nil")
+	assert.EqualValues(t, fileHeaderSize, info.Size(), "Should empty the file with the buffer")
+	assert.Nil(t, b.store.close(), "Error should be nil")
+
+	t.Setenv("VIAM_MODULE_DATA", "")
+	_, err = newSampleBuffer(conf, sensorConf, newMsg)
+	assert.NotNil(t, err, "Should need VIAM_MODULE_DATA")
+}
+
+func TestDiskBufferCompacts(t *testing.T) {
+	s, err := openDiskStore(filepath.Join(t.TempDir(), "buffer"), func() (interface{}, error) { return &std_msgs.Int32{}, nil })
+	assert.Nil(t, err, "Error should be nil")
+	defer s.close()
+
+	// dropping the oldest records past 1MB moves the others to the start of the file
+	n := 0
+	for ; s.end <= compactBytes+1000; n++ {
+		assert.Nil(t, s.push(&std_msgs.Int32{Data: int32(n)}, time.Now()), "Error should be nil")
+	}
+	compacted := false
+	for !compacted && s.len() > 1 {
+		assert.Nil(t, s.discard(), "Error should be nil")
+		compacted = s.head == 0
+	}
+	assert.True(t, compacted, "Should compact the file")
+	assert.Less(t, s.end, int64(compactBytes))
+	info, err := s.f.Stat()
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, s.end, info.Size())
+	for s.len() > 1 {
+		assert.Nil(t, s.discard(), "Error should be nil")
+	}
+	msg, err := s.pop()
+	assert.Nil(t, err, "Error should be nil")
+	assert.EqualValues(t, n-1, msg.(*std_msgs.Int32).Data, "Should keep the records in order")
+}
+
+func TestDiskBufferReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer")
+	open := func() *diskStore {
+		s, err := openDiskStore(path, func() (interface{}, error) { return &std_msgs.Int32{}, nil })
+		assert.Nil(t, err, "Error should be nil")
+		return s
+	}
+	pop := func(s *diskStore) int32 {
+		msg, err := s.pop()
+		assert.Nil(t, err, "Error should be nil")
+		return msg.(*std_msgs.Int32).Data
+	}
+
+	s := open()
+	for _, v := range []int32{1, 2, 3, 4} {
+		assert.Nil(t, s.push(&std_msgs.Int32{Data: v}, time.Now()), "Error should be nil")
+	}
+	assert.EqualValues(t, 1, pop(s))
+	assert.Nil(t, s.discard(), "Error should be nil")
+	// the process dies without closing the store
+	s.f.Close()
+
+	s = open()
+	assert.Equal(t, 2, s.len(), "Should not replay the messages sent or dropped before a restart")
+	assert.EqualValues(t, 3, pop(s))
+	s.f.Close()
+
+	// a compaction that died before replacing the file
+	assert.Nil(t, os.WriteFile(path+".tmp", []byte{8, 0, 0, 0, 0, 0, 0, 0, 4}, 0o644))
+	s = open()
+	assert.NoFileExists(t, path+".tmp", "Should remove the file of an interrupted compaction")
+	assert.Equal(t, 1, s.len(), "Should keep the buffer of an interrupted compaction")
+	assert.EqualValues(t, 4, pop(s))
+
+	// emptying the buffer died between cutting the file and writing the head
+	assert.Nil(t, s.push(&std_msgs.Int32{Data: 5}, time.Now()), "Error should be nil")
+	assert.Nil(t, s.push(&std_msgs.Int32{Data: 6}, time.Now()), "Error should be nil")
+	assert.Nil(t, s.discard(), "Error should be nil")
+	assert.Nil(t, s.f.Truncate(fileHeaderSize), "Error should be nil")
+	s.f.Close()
+	s = open()
+	assert.Equal(t, 0, s.len(), "Should not replay an emptied buffer")
+	assert.Nil(t, s.close(), "Error should be nil")
+}
+
+func TestBufferWhileDisconnected(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	cfg, deps := component_test_setup(t)
+	deps[sensor.Named("uptime")] = &fakeSensor{Named: sensor.Named("uptime").AsNamed()}
+	// nothing listens on port 1, so every sample is buffered
+	cfg.ConvertedAttributes = &RosBridgeConfig{
+		PrimaryUri: "127.0.0.1:1",
+		Host:       "127.0.0.1",
+		Sensors: []*SensorConfig{{
+			Topic: "/uptime", Type: "std_msgs/Int32", Name: "uptime", SampleRate: 100,
+			Buffer: &BufferConfig{MaxMessages: 5},
+		}},
+		ConnectRetry: &utils.RetryConfig{InitialDelayMs: 5, MaxDelayMs: 5},
+	}
+
+	res, err := NewRosSensorPublisher(context.Background(), deps, cfg, logger)
+	assert.Nil(t, err, "Error should be nil")
+	defer res.Close(context.Background())
+
+	stats := func() map[string]interface{} {
+		out, err := res.DoCommand(context.Background(), map[string]interface{}{"command": "stats"})
+		assert.Nil(t, err, "Error should be nil")
+		return out["uptime"].(map[string]interface{})
+	}
+	assert.Eventually(t, func() bool { return stats()["buffer_dropped"].(int64) > 0 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 5, stats()["buffered"], "Should keep max_messages messages")
+}
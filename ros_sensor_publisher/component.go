@@ -2,8 +2,10 @@ package ros_sensor_publisher
 
 import (
 	"context"
-	"strings"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bluenviron/goroslib/v2"
@@ -15,10 +17,15 @@ import (
 
 	"github.com/viam-soleng/viam-ros-sensor-bridge/messages"
 	"github.com/viam-soleng/viam-ros-sensor-bridge/utils"
+	"github.com/viam-soleng/viam-ros-sensor-bridge/viamrosnode"
 )
 
 var Model = resource.NewModel(utils.Namespace, "ros", "sensor-publisher")
 
+var ErrUnknownCommand = errors.New("unknown command")
+var ErrUnknownSensor = errors.New("unknown sensor")
+var ErrNotConnected = errors.New("publisher is not connected")
+
 func init() {
 	resource.RegisterComponent(
 		generic.API,
@@ -52,6 +59,8 @@ type RosSensorPublisher struct {
 	logger     logging.Logger
 	cancelFunc context.CancelFunc
 	ctx        context.Context
+	conf       *RosBridgeConfig
+	readers    []*RosReader
 }
 
 // Close implements resource.Resource.
@@ -64,9 +73,81 @@ func (r *RosSensorPublisher) Close(ctx context.Context) error {
 	return nil
 }
 
-// DoCommand implements resource.Resource.
-func (*RosSensorPublisher) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
-	return map[string]interface{}{"ok": 1}, nil
+// DoCommand implements resource.Resource. Commands other than stats and connection apply to the
+// sensor named by "sensor", or to every sensor when it is omitted, and return the status of each.
+func (r *RosSensorPublisher) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	command := cmd["command"]
+	switch command {
+	case "stats", "connection", "status", "pause", "resume", "publish_now", "reconnect":
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnknownCommand, command)
+	}
+
+	// The readers are used without the lock, so waiting for publish_now doesn't hold up a
+	// reconfigure. A reader stopped meanwhile fails the command.
+	r.mu.RLock()
+	all := r.readers
+	readers, err := r.selectReaders(cmd["sensor"])
+	r.mu.RUnlock()
+
+	switch command {
+	case "stats":
+		stats := map[string]interface{}{}
+		for _, reader := range all {
+			stats[reader.sensorConfig.Name] = reader.stats()
+		}
+		return stats, nil
+	case "connection":
+		status := map[string]interface{}{}
+		for _, reader := range all {
+			status[reader.sensorConfig.Name] = reader.monitor.Status()
+		}
+		return status, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	status := map[string]interface{}{}
+	for _, reader := range readers {
+		switch command {
+		case "pause":
+			reader.paused.Store(true)
+			r.logger.Infof("Paused sensor %v", reader.sensorConfig.Name)
+		case "resume":
+			reader.paused.Store(false)
+			r.logger.Infof("Resumed sensor %v", reader.sensorConfig.Name)
+		case "publish_now":
+			if err := reader.publishNow(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%v: %w", reader.sensorConfig.Name, err))
+			}
+		case "reconnect":
+			reader.monitor.RequestReconnect("reconnect command")
+		}
+		status[reader.sensorConfig.Name] = reader.status()
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// selectReaders returns the reader of the sensor named name, or every reader when name is nil.
+func (r *RosSensorPublisher) selectReaders(name interface{}) ([]*RosReader, error) {
+	if name == nil {
+		return r.readers, nil
+	}
+	var readers []*RosReader
+	for _, reader := range r.readers {
+		if reader.sensorConfig.Name == name {
+			readers = append(readers, reader)
+		}
+	}
+	if len(readers) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownSensor, name)
+	}
+	return readers, nil
 }
 
 // Reconfigure implements resource.Resource.
@@ -80,29 +161,37 @@ func (r *RosSensorPublisher) Reconfigure(ctx context.Context, deps resource.Depe
 		return err
 	}
 
+	// Load the runtime message types before anything tries to look them up
+	if err := messages.LoadMessageDefinitions(r.logger, newConf.MessageDefinitions); err != nil {
+		return err
+	}
+	if newConf.definitionsSum, err = messages.DefinitionsChecksum(newConf.MessageDefinitions); err != nil {
+		return err
+	}
+
 	// In case the module has changed name
 	r.Named = conf.ResourceName().AsNamed()
 
 	return r.reconfigure(newConf, deps)
 }
 
+// reconfigure only restarts the readers whose sensor, topic, message type, sample rate, latch or
+// buffer changed, or all of them when the connection settings or message definitions changed. The
+// other readers keep publishing, with their field mapping, strict mode and frame id updated in place.
 func (r *RosSensorPublisher) reconfigure(newConf *RosBridgeConfig, deps resource.Dependencies) error {
-	r.logger.Debug("Stopping existing readers")
-
-	// call the cancel func to stop all readers
-	r.cancelFunc()
-
-	// wait for the readers to stop
-	r.wg.Wait()
-	r.logger.Debug("Readers stopped")
+	restartAll := r.conf == nil || !newConf.sameConnection(r.conf)
+	r.conf = newConf
 
-	// Recreate the context since we cancelled it above
-	c, cancelFunc := context.WithCancel(context.Background())
-	r.cancelFunc = cancelFunc
-	r.ctx = c
+	// old readers by sensor name and topic, in config order
+	old := map[readerKey][]*RosReader{}
+	for _, reader := range r.readers {
+		key := readerKey{reader.sensorConfig.Name, reader.sensorConfig.Topic}
+		old[key] = append(old[key], reader)
+	}
 
+	var readers, started []*RosReader
+	replacements := map[*RosReader]*RosReader{}
 	for _, s := range newConf.Sensors {
-		r.logger.Debugf("Creating sensor %v", s.Name)
 		d, err := deps.Lookup(
 			resource.Name{
 				API:  sensor.API,
@@ -113,164 +202,489 @@ func (r *RosSensorPublisher) reconfigure(newConf *RosBridgeConfig, deps resource
 			continue
 		}
 
-		r.logger.Debugf("Forking reader %v", s.Name)
-		reader := RosReader{
-			primaryUri:       newConf.PrimaryUri,
-			host:             newConf.Host,
-			sensorConfig:     s,
-			sensor:           d.(sensor.Sensor),
-			logger:           r.logger,
-			wg:               &r.wg,
-			ctx:              r.ctx,
-			requestReconnect: make(chan interface{}, 100),
+		if s.SampleRate == 0 {
+			r.logger.Warnf("Sample rate is 0, defaulting to 1Hz %v", s.Name)
+			s.SampleRate = 1
+		}
+
+		key := readerKey{s.Name, s.Topic}
+		var replaced *RosReader
+		if kept := old[key]; len(kept) > 0 {
+			replaced = kept[0]
+			old[key] = kept[1:]
+			if replaced.sensor == d && !restartAll && !replaced.sensorConfig.restartNeeded(s) {
+				r.logger.Debugf("Keeping reader %v", s.Name)
+				replaced.update(s)
+				readers = append(readers, replaced)
+				continue
+			}
+			// changed, the reader is stopped below with the other old ones
+			old[key] = append(old[key], replaced)
+		}
+
+		r.logger.Debugf("Creating sensor %v", s.Name)
+		c, cancelFunc := context.WithCancel(r.ctx)
+		reader := &RosReader{
+			primaryUri:   newConf.PrimaryUri,
+			host:         newConf.Host,
+			retry:        newConf.ConnectRetry,
+			sensorConfig: s,
+			sensor:       d.(sensor.Sensor),
+			logger:       r.logger,
+			wg:           &r.wg,
+			ctx:          c,
+			cancelFunc:   cancelFunc,
+			done:         make(chan struct{}),
+			now:          make(chan chan error),
+			scheduler:    newScheduler(s.SampleRate),
+		}
+		if replaced != nil {
+			// a paused sensor stays paused through a restart
+			reader.paused.Store(replaced.paused.Load())
+			replacements[reader] = replaced
+		}
+		reader.monitor = utils.NewConnectionMonitor(r.logger, reader.connect, reader.probe)
+		readers = append(readers, reader)
+		started = append(started, reader)
+	}
+
+	// stop the readers that weren't kept before starting their replacements
+	r.logger.Debug("Stopping changed readers")
+	for _, stopped := range old {
+		for _, reader := range stopped {
+			if !contains(readers, reader) {
+				reader.stop()
+			}
 		}
+	}
+	r.logger.Debug("Readers stopped")
+
+	// the topic of a latched sensor keeps its value through a restart, as long as the type is the same
+	for reader, replaced := range replacements {
+		if reader.sensorConfig.Latch && reader.sensorConfig.Type == replaced.sensorConfig.Type {
+			replaced.mu.Lock()
+			reader.latched = replaced.latched
+			replaced.mu.Unlock()
+		}
+	}
+
+	for _, reader := range started {
+		r.logger.Debugf("Forking reader %v", reader.sensorConfig.Name)
+		r.wg.Add(1)
 		viamutils.PanicCapturingGo(reader.read())
 	}
+	r.readers = readers
 	return nil
 }
 
-type RosReader struct {
-	primaryUri       string
-	host             string
-	sensorConfig     *SensorConfig
-	sensor           sensor.Sensor
-	logger           logging.Logger
-	wg               *sync.WaitGroup
-	ctx              context.Context
-	p                *goroslib.Publisher
-	n                *goroslib.Node
-	mu               sync.Mutex
-	requestReconnect chan interface{}
+// rosPublisher is what readers use of a goroslib publisher
+type rosPublisher interface {
+	Write(msg interface{})
+	Close()
+}
+
+// getRosNode, releaseNode and newPublisher are swapped out by the tests
+var getRosNode = utils.GetRosNodeWithRetry
+var releaseNode = (*viamrosnode.Handle).Release
+var newPublisher = func(node *viamrosnode.Handle, conf goroslib.PublisherConf) (rosPublisher, error) {
+	conf.Node = node.Node()
+	return goroslib.NewPublisher(conf)
+}
+
+// readerKey identifies the reader of a sensor across reconfigures
+type readerKey struct {
+	name  string
+	topic string
 }
 
-func (r *RosReader) onLog(level goroslib.LogLevel, msg string) {
-	if level == goroslib.LogLevelFatal {
-		r.logger.Fatal(msg)
-	} else if level == goroslib.LogLevelError {
-		r.logger.Error(msg)
-	} else if level == goroslib.LogLevelWarn {
-		r.logger.Warn(msg)
-	} else if level == goroslib.LogLevelInfo {
-		r.logger.Info(msg)
-	} else if level == goroslib.LogLevelDebug {
-		r.logger.Debug(msg)
-	} else {
-		r.logger.Errorf("Unknown log level: %v, msg: %v", level, msg)
-	}
-
-	if strings.Contains(msg, "got an error") && strings.Contains(msg, "dial tcp") {
-		r.logger.Warn("Got a dial tcp error, requesting reconnect")
-		r.mu.Lock()
-		defer r.mu.Unlock()
-		r.requestReconnect <- struct{}{}
+func contains(readers []*RosReader, reader *RosReader) bool {
+	for _, other := range readers {
+		if other == reader {
+			return true
+		}
 	}
+	return false
 }
 
-func (r *RosReader) connect() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+type RosReader struct {
+	primaryUri   string
+	host         string
+	retry        *utils.RetryConfig
+	sensorConfig *SensorConfig
+	sensor       sensor.Sensor
+	logger       logging.Logger
+	wg           *sync.WaitGroup
+	ctx          context.Context
+	cancelFunc   context.CancelFunc
+	// done is closed when read returns
+	done chan struct{}
+	p    rosPublisher
+	n    *viamrosnode.Handle
+	mu   sync.Mutex
+	// latched is the last message of a latched topic, written again after reconnecting
+	latched interface{}
+	monitor *utils.ConnectionMonitor
+	// seq numbers the headers of the published messages
+	seq       uint32
+	scheduler *scheduler
+	// last is the last message published, at lastPublish
+	last        interface{}
+	lastPublish time.Time
+	suppressed  atomic.Int64
+	reads       atomic.Int64
+	published   atomic.Int64
+	failures    atomic.Int64
+	paused      atomic.Bool
+	// now receives the samples publish_now forces, and returns their result
+	now chan chan error
+	// writtenAt is when a message was last written to the publisher, lastErr is the last error of
+	// the reader, at lastErrAt
+	writtenAt time.Time
+	lastErr   error
+	lastErrAt time.Time
+	// buffer keeps the messages written while disconnected, nil when the sensor has none
+	buffer *sampleBuffer
+	// connectedAt is when the publisher was created, the buffer is replayed replaySettle after
+	connectedAt time.Time
+}
 
+func (r *RosReader) connect(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil && ctx.Err() == nil {
+			r.fail(err)
+		}
+	}()
+	r.mu.Lock()
 	r.logger.Debugf("Shutting down existing publisher %v", r.sensorConfig.Topic)
 	if r.p != nil {
 		r.p.Close()
 	}
 	r.p = nil
-	// Shutdown any existing nodes
+	// Release the node, it is only closed if no other publisher or subscriber uses it
 	if r.n != nil {
-		r.n.Close()
+		releaseNode(r.n)
 	}
+	r.n = nil
+	r.mu.Unlock()
 
+	// Don't hold the lock while waiting for the master, the reader skips publishing meanwhile
 	r.logger.Debugf("Connecting to %v", r.primaryUri)
-	node := utils.GetRosNodeWithRetry(r.logger, r.primaryUri, r.host, r.onLog)
+	node, err := getRosNode(ctx, r.logger, r.primaryUri, r.host, r.retry, viamrosnode.Topic{Name: r.sensorConfig.Topic, Publish: true})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.n = node
 
 	// Get the type of the message so we can create the publisher later
 	messageType, err := messages.GetMessageType(r.sensorConfig.Type)
 	if err != nil {
-		r.logger.Error(err)
-		return
+		return err
 	}
 	r.logger.Debugf("Creating publisher %v", r.sensorConfig.Topic)
-	publisher, err := goroslib.NewPublisher(goroslib.PublisherConf{
-		Node:  node,
+	publisher, err := newPublisher(node, goroslib.PublisherConf{
 		Topic: r.sensorConfig.Topic,
 		Msg:   messageType,
+		Latch: r.sensorConfig.Latch,
 	})
 	if err == goroslib.ErrNodeTerminated {
 		r.logger.Debugf("Node terminated %v", r.sensor.Name().Name)
-		r.logger.Debugf("%v", node == nil)
-		return
+		// make the next reconnect create a new node
+		node.Discard()
 	}
 	if err != nil {
-		r.logger.Error(err)
-		return
+		return err
 	}
 	r.p = publisher
+	r.connectedAt = time.Now()
+	if r.latched != nil {
+		// the new publisher has to latch the value the old one had
+		r.p.Write(r.latched)
+	}
+	return nil
+}
+
+// probe checks that the master is reachable and still has the publisher registered.
+func (r *RosReader) probe() error {
+	r.mu.Lock()
+	node, ok := r.n, r.p != nil
+	r.mu.Unlock()
+	if node == nil || !ok {
+		return fmt.Errorf("%w: no publisher", utils.ErrDegraded)
+	}
+	return utils.ProbeTopic(node, r.sensorConfig.Topic, true)
 }
 
 func (r *RosReader) read() func() {
 	return func() {
 		r.logger.Infof("Starting reader %v", r.sensor.Name().Name)
-		// increment the waitgroup to make sure we wait for this reader to stop
-		r.wg.Add(1)
 		defer func() {
-			// release the waitgroup when this reader stops
+			// release the waitgroup when this reader stops, it was incremented when the reader started
+			close(r.done)
 			r.wg.Done()
 			r.logger.Debugf("Reader fully stopped %v", r.sensor.Name().Name)
 		}()
 
-		r.connect()
+		if r.sensorConfig.Buffer != nil {
+			buffer, err := newSampleBuffer(r.sensorConfig.Buffer, r.sensorConfig, func() (interface{}, error) {
+				return messages.GetMessageType(r.sensorConfig.Type)
+			})
+			if err != nil {
+				r.logger.Errorf("cannot create the buffer of %v, messages won't be buffered: %v", r.sensorConfig.Name, err)
+			}
+			r.mu.Lock()
+			r.buffer = buffer
+			r.mu.Unlock()
+		}
+
+		monitorDone := make(chan struct{})
+		viamutils.PanicCapturingGo(func() {
+			defer close(monitorDone)
+			r.monitor.Run(r.ctx)
+		})
 		// We need to close the publisher when this reader stops
 		defer func() {
+			<-monitorDone
+			r.mu.Lock()
+			defer r.mu.Unlock()
 			r.logger.Debugf("Closing publisher %v", r.sensorConfig.Topic)
 			if r.p != nil {
 				r.p.Close()
 			}
-			r.logger.Debugf("Closing node %v", r.sensor.Name().Name)
+			r.logger.Debugf("Releasing node %v", r.sensor.Name().Name)
 			if r.n != nil {
-				r.n.Close()
+				releaseNode(r.n)
+			}
+			if r.buffer != nil {
+				// a disk buffer keeps its messages for the next reader
+				if err := r.buffer.store.close(); err != nil {
+					r.logger.Errorf("cannot close the buffer of %v: %v", r.sensorConfig.Name, err)
+				}
 			}
 		}()
-		if r.sensorConfig.SampleRate == 0 {
-			r.logger.Warnf("Sample rate is 0, defaulting to 1Hz %v", r.sensor.Name().Name)
-			r.sensorConfig.SampleRate = 1
-		}
-		lastReconnectRequest := time.Now()
-		timer := time.NewTimer(time.Duration(1/r.sensorConfig.SampleRate) * time.Second)
+		r.scheduler.begin()
+		defer r.scheduler.stop()
 		for {
 			select {
-			case <-r.requestReconnect:
-				if time.Since(lastReconnectRequest) < 1*time.Second {
-					continue
-				}
-				r.logger.Infof("Reconnecting %v", r.sensor.Name().Name)
-				r.connect()
-				lastReconnectRequest = time.Now()
 			case <-r.ctx.Done():
 				r.logger.Debugf("Reader recevied shutdown signal %v", r.sensor.Name().Name)
 				return
-			case <-timer.C:
-				r.logger.Debugf("Reading sensor %v", r.sensor.Name().Name)
-				readings, err := r.sensor.Readings(r.ctx, map[string]interface{}{})
-				if err != nil {
-					r.logger.Error(err)
+			case <-r.scheduler.C():
+				r.scheduler.tick()
+				r.flush()
+				if r.paused.Load() {
 					continue
 				}
+				r.sample(false)
+			case result := <-r.now:
+				result <- r.sample(true)
+			}
+		}
+	}
+}
 
-				d, e := messages.ConvertToRosMsg(r.sensorConfig.Type, readings)
-				if e != nil {
-					r.logger.Error(e)
-					continue
-				}
-				r.logger.Debugf("Publishing message %v", r.sensor.Name().Name)
-				// Only try to write if the publisher is there
-				if r.p != nil {
-					r.p.Write(d)
-				} else {
-					r.logger.Warnf("Publisher is nil %v, this could mean ROS isn't responding to connection attempts or we are attempting to reconnect", r.sensor.Name().Name)
-				}
-				timer.Reset(1 * time.Second)
+// sample reads the sensor and publishes its readings if the publish mode lets it, or anyway when
+// force is set.
+func (r *RosReader) sample(force bool) error {
+	r.logger.Debugf("Reading sensor %v", r.sensor.Name().Name)
+	r.reads.Add(1)
+	readings, err := r.sensor.Readings(r.ctx, map[string]interface{}{})
+	if err != nil {
+		r.logger.Error(err)
+		return r.fail(err)
+	}
+	readAt := time.Now()
+
+	// the settings that can be updated while the reader runs
+	r.mu.Lock()
+	conf := *r.sensorConfig
+	r.mu.Unlock()
+	convert := messages.ConvertToRosMsg
+	if conf.Strict {
+		convert = messages.ConvertToRosMsgStrict
+	}
+	d, err := convert(conf.Type, conf.FieldMap.Apply(readings))
+	if err != nil {
+		err = fmt.Errorf("cannot convert readings of %v to %v: %w", conf.Name, conf.Type, err)
+		r.logger.Error(err)
+		return r.fail(err)
+	}
+	if !force && !r.shouldPublish(&conf, d, readAt) {
+		r.suppressed.Add(1)
+		return nil
+	}
+	r.seq++
+	messages.FillHeader(d, readAt, r.seq, conf.FrameId)
+	r.logger.Debugf("Publishing message %v", r.sensor.Name().Name)
+	if err := r.write(d, readAt); err != nil {
+		return r.fail(err)
+	}
+	r.last, r.lastPublish = d, readAt
+	return nil
+}
+
+// publishNow makes the reader sample the sensor right away and publish the readings.
+func (r *RosReader) publishNow(ctx context.Context) error {
+	result := make(chan error, 1)
+	select {
+	case r.now <- result:
+	case <-r.done:
+		return r.ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fail records err as the last error of the reader and returns it.
+func (r *RosReader) fail(err error) error {
+	r.failures.Add(1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastErr, r.lastErrAt = err, time.Now()
+	return err
+}
+
+// stop stops the reader and waits for it to release its publisher.
+func (r *RosReader) stop() {
+	r.cancelFunc()
+	<-r.done
+}
+
+// update applies the settings that don't need a restart: field_map, strict, frame_id and the
+// publish mode.
+func (r *RosReader) update(s *SensorConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sensorConfig.FieldMap = s.FieldMap
+	r.sensorConfig.Strict = s.Strict
+	r.sensorConfig.FrameId = s.FrameId
+	r.sensorConfig.PublishMode = s.PublishMode
+	r.sensorConfig.Deadband = s.Deadband
+	r.sensorConfig.MaxSilenceSecs = s.MaxSilenceSecs
+}
+
+// shouldPublish applies the publish mode of conf to d, the message of a sample read at readAt.
+func (r *RosReader) shouldPublish(conf *SensorConfig, d interface{}, readAt time.Time) bool {
+	var deadband float64
+	switch conf.PublishMode {
+	case PublishOnChange:
+	case PublishDeadband:
+		deadband = conf.Deadband
+	default:
+		return true
+	}
+	if messages.Changed(r.last, d, deadband) {
+		return true
+	}
+	// the heartbeat shows subscribers the topic is still alive
+	maxSilence := time.Duration(conf.MaxSilenceSecs * float64(time.Second))
+	return maxSilence > 0 && readAt.Sub(r.lastPublish) >= maxSilence
+}
+
+// write publishes d, read at readAt, ErrNotConnected when there is no publisher to write to. With
+// a buffer, d is buffered until the buffered messages can be replayed before it.
+func (r *RosReader) write(d interface{}, readAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buffer != nil {
+		if r.p == nil || time.Since(r.connectedAt) < replaySettle {
+			if err := r.buffer.push(d, readAt); err != nil {
+				err = fmt.Errorf("cannot buffer message of %v: %w", r.sensorConfig.Name, err)
+				r.logger.Error(err)
+				return err
 			}
+			return nil
 		}
+		r.replay()
+	}
+	// Only try to write if the publisher is there
+	if r.p != nil {
+		r.publish(d)
+		return nil
+	}
+	r.logger.Warnf("Publisher is nil %v, this could mean ROS isn't responding to connection attempts or we are attempting to reconnect", r.sensor.Name().Name)
+	return ErrNotConnected
+}
+
+// publish writes d to the publisher, r.mu must be held.
+func (r *RosReader) publish(d interface{}) {
+	r.p.Write(d)
+	r.published.Add(1)
+	r.writtenAt = time.Now()
+	if r.sensorConfig.Latch {
+		r.latched = d
+	}
+}
+
+// flush replays the buffer once the publisher had time to get its subscribers back, even when the
+// publish mode suppresses the next samples.
+func (r *RosReader) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buffer != nil && r.p != nil && time.Since(r.connectedAt) >= replaySettle {
+		r.replay()
+	}
+}
+
+// replay writes the buffered messages in order, r.mu must be held.
+func (r *RosReader) replay() {
+	if r.buffer.store.len() == 0 {
+		return
+	}
+	n, err := r.buffer.replay(time.Now(), r.publish)
+	if err != nil {
+		r.logger.Errorf("cannot replay buffered messages of %v: %v", r.sensorConfig.Name, err)
+	}
+	r.logger.Infof("Replayed %v buffered messages of %v", n, r.sensorConfig.Name)
+}
+
+// stats returns the scheduler stats, the number of samples the publish mode suppressed and the
+// state of the buffer.
+func (r *RosReader) stats() map[string]interface{} {
+	stats := r.scheduler.stats()
+	stats["suppressed"] = r.suppressed.Load()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buffer != nil {
+		stats["buffered"] = r.buffer.store.len()
+		stats["buffer_dropped"] = r.buffer.dropped
+	}
+	return stats
+}
+
+// status returns the stats of the reader with its connection state, whether it is paused, the
+// number of reads, messages published and errors, and the last publish and error.
+func (r *RosReader) status() map[string]interface{} {
+	status := r.stats()
+	status["state"] = string(r.monitor.State())
+	status["paused"] = r.paused.Load()
+	status["reads"] = r.reads.Load()
+	status["published"] = r.published.Load()
+	status["errors"] = r.failures.Load()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status["last_publish"] = formatTime(r.writtenAt)
+	status["last_error"] = ""
+	if r.lastErr != nil {
+		status["last_error"] = r.lastErr.Error()
+	}
+	status["last_error_at"] = formatTime(r.lastErrAt)
+	return status
+}
+
+// formatTime formats t as RFC 3339, the zero time as an empty string.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
 	}
+	return t.Format(time.RFC3339Nano)
 }
@@ -1,11 +1,22 @@
 package ros_sensor_publisher
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/bluenviron/goroslib/v2"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+	"github.com/stretchr/testify/assert"
 	"go.viam.com/rdk/components/generic"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/utils"
+	"github.com/viam-soleng/viam-ros-sensor-bridge/viamrosnode"
 )
 
 func component_test_setup(t *testing.T) (resource.Config, resource.Dependencies) {
@@ -19,3 +30,347 @@ func component_test_setup(t *testing.T) (resource.Config, resource.Dependencies)
 
 	return cfg, resource.Dependencies{}
 }
+
+type fakeSensor struct {
+	resource.Named
+	resource.TriviallyReconfigurable
+	resource.TriviallyCloseable
+}
+
+func (*fakeSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{"Data": 1}, nil
+}
+
+func (*fakeSensor) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func TestReconfigureKeepsUnchangedReaders(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	cfg, deps := component_test_setup(t)
+	for _, name := range []string{"uptime", "temperature", "humidity"} {
+		deps[sensor.Named(name)] = &fakeSensor{Named: sensor.Named(name).AsNamed()}
+	}
+	conf := func(sensors ...*SensorConfig) resource.Config {
+		// nothing listens on port 1, so the readers keep retrying
+		cfg.ConvertedAttributes = &RosBridgeConfig{
+			PrimaryUri:   "127.0.0.1:1",
+			Host:         "127.0.0.1",
+			Sensors:      sensors,
+			ConnectRetry: &utils.RetryConfig{InitialDelayMs: 5, MaxDelayMs: 5},
+		}
+		return cfg
+	}
+	uptime := func() *SensorConfig {
+		return &SensorConfig{Topic: "/uptime", Type: "std_msgs/Int32", Name: "uptime", SampleRate: 10}
+	}
+	temperature := func() *SensorConfig {
+		return &SensorConfig{Topic: "/temperature", Type: "std_msgs/Int32", Name: "temperature", SampleRate: 10}
+	}
+
+	res, err := NewRosSensorPublisher(context.Background(), deps, conf(uptime(), temperature()), logger)
+	assert.Nil(t, err, "Error should be nil")
+	p := res.(*RosSensorPublisher)
+	defer p.Close(context.Background())
+	first := append([]*RosReader{}, p.readers...)
+	assert.Len(t, first, 2)
+
+	// only the temperature rate changed
+	changed := temperature()
+	changed.SampleRate = 5
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, conf(uptime(), changed)), "Error should be nil")
+	assert.Same(t, first[0], p.readers[0], "Should keep the unchanged reader")
+	assert.NotSame(t, first[1], p.readers[1], "Should restart the changed reader")
+	assert.NotNil(t, first[1].ctx.Err(), "Should stop the changed reader")
+	assert.Nil(t, first[0].ctx.Err())
+
+	// settings that are updated in place
+	updated := uptime()
+	updated.FrameId = "base_link"
+	updated.Strict = true
+	second := append([]*RosReader{}, p.readers...)
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, conf(updated, changed, &SensorConfig{Topic: "/humidity", Type: "std_msgs/Int32", Name: "humidity"})), "Error should be nil")
+	assert.Len(t, p.readers, 3)
+	assert.Same(t, second[0], p.readers[0], "Should update the reader in place")
+	assert.Equal(t, "base_link", p.readers[0].sensorConfig.FrameId)
+	assert.True(t, p.readers[0].sensorConfig.Strict)
+	assert.Same(t, second[1], p.readers[1])
+
+	// removed sensors are stopped
+	third := append([]*RosReader{}, p.readers...)
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, conf(updated)), "Error should be nil")
+	assert.Equal(t, []*RosReader{third[0]}, p.readers)
+	assert.NotNil(t, third[1].ctx.Err())
+	assert.NotNil(t, third[2].ctx.Err())
+
+	// a rebuilt dependency restarts its reader
+	deps[sensor.Named("uptime")] = &fakeSensor{Named: sensor.Named("uptime").AsNamed()}
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, conf(updated)), "Error should be nil")
+	assert.NotSame(t, third[0], p.readers[0], "Should restart the reader of a rebuilt sensor")
+	assert.NotNil(t, third[0].ctx.Err())
+
+	// a new master restarts everything
+	fourth := p.readers[0]
+	next := conf(updated)
+	next.ConvertedAttributes.(*RosBridgeConfig).PrimaryUri = "127.0.0.1:2"
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, next), "Error should be nil")
+	assert.NotSame(t, fourth, p.readers[0], "Should restart readers when the master changes")
+	assert.NotNil(t, fourth.ctx.Err())
+
+	// editing a definition file restarts everything, even though the paths are the same
+	dir := t.TempDir()
+	msgFile := filepath.Join(dir, "test_msgs", "Count.msg")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(msgFile), 0o755))
+	assert.Nil(t, os.WriteFile(msgFile, []byte("int32 count\n"), 0o644))
+	withDefinitions := func() resource.Config {
+		c := conf(updated)
+		c.ConvertedAttributes.(*RosBridgeConfig).PrimaryUri = "127.0.0.1:2"
+		c.ConvertedAttributes.(*RosBridgeConfig).MessageDefinitions = []string{dir}
+		return c
+	}
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, withDefinitions()), "Error should be nil")
+	fifth := p.readers[0]
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, withDefinitions()), "Error should be nil")
+	assert.Same(t, fifth, p.readers[0], "Should keep readers when the definitions are the same")
+	assert.Nil(t, os.WriteFile(msgFile, []byte("int64 count\n"), 0o644))
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, withDefinitions()), "Error should be nil")
+	assert.NotSame(t, fifth, p.readers[0], "Should restart readers when a definition file is edited")
+}
+
+func TestPublishModes(t *testing.T) {
+	start := time.Now()
+	publish := func(r *RosReader, conf *SensorConfig, data int32, at time.Duration) bool {
+		d := &std_msgs.Int32{Data: data}
+		if !r.shouldPublish(conf, d, start.Add(at)) {
+			return false
+		}
+		r.last, r.lastPublish = d, start.Add(at)
+		return true
+	}
+
+	r := &RosReader{}
+	periodic := &SensorConfig{}
+	assert.True(t, publish(r, periodic, 1, 0))
+	assert.True(t, publish(r, periodic, 1, time.Second), "Should publish every sample by default")
+
+	r = &RosReader{}
+	onChange := &SensorConfig{PublishMode: PublishOnChange}
+	assert.True(t, publish(r, onChange, 1, 0), "Should publish the first sample")
+	assert.False(t, publish(r, onChange, 1, time.Second))
+	assert.True(t, publish(r, onChange, 2, 2*time.Second))
+	assert.False(t, publish(r, onChange, 2, time.Hour), "Should not publish a heartbeat by default")
+
+	r = &RosReader{}
+	deadband := &SensorConfig{PublishMode: PublishDeadband, Deadband: 5, MaxSilenceSecs: 10}
+	assert.True(t, publish(r, deadband, 100, 0))
+	assert.False(t, publish(r, deadband, 104, time.Second))
+	assert.False(t, publish(r, deadband, 96, 2*time.Second))
+	assert.True(t, publish(r, deadband, 106, 3*time.Second), "Should compare with the last published sample")
+	assert.False(t, publish(r, deadband, 106, 12*time.Second))
+	assert.True(t, publish(r, deadband, 106, 13*time.Second), "Should publish a heartbeat after max_silence_secs")
+}
+
+func TestDoCommand(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	cfg, deps := component_test_setup(t)
+	deps[sensor.Named("uptime")] = &fakeSensor{Named: sensor.Named("uptime").AsNamed()}
+	conf := func(rate float64) resource.Config {
+		// nothing listens on port 1, so nothing can be published
+		cfg.ConvertedAttributes = &RosBridgeConfig{
+			PrimaryUri:   "127.0.0.1:1",
+			Host:         "127.0.0.1",
+			Sensors:      []*SensorConfig{{Topic: "/uptime", Type: "std_msgs/Int32", Name: "uptime", SampleRate: rate}},
+			ConnectRetry: &utils.RetryConfig{InitialDelayMs: 5, MaxDelayMs: 5},
+		}
+		return cfg
+	}
+	res, err := NewRosSensorPublisher(context.Background(), deps, conf(100), logger)
+	assert.Nil(t, err, "Error should be nil")
+	defer res.Close(context.Background())
+	do := func(cmd ...string) (map[string]interface{}, error) {
+		c := map[string]interface{}{"command": cmd[0]}
+		if len(cmd) > 1 {
+			c["sensor"] = cmd[1]
+		}
+		return res.DoCommand(context.Background(), c)
+	}
+	status := func() map[string]interface{} {
+		out, err := do("status")
+		assert.Nil(t, err, "Error should be nil")
+		return out["uptime"].(map[string]interface{})
+	}
+
+	assert.Eventually(t, func() bool { return status()["errors"].(int64) > 0 }, time.Second, 10*time.Millisecond)
+	s := status()
+	assert.Equal(t, ErrNotConnected.Error(), s["last_error"])
+	assert.NotEmpty(t, s["last_error_at"])
+	assert.Equal(t, "", s["last_publish"])
+	assert.EqualValues(t, 0, s["published"])
+	assert.Contains(t, []interface{}{"connecting", "disconnected"}, s["state"])
+	assert.Contains(t, s, "rate_hz", "Should include the stats")
+
+	out, err := do("pause", "uptime")
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, true, out["uptime"].(map[string]interface{})["paused"])
+	time.Sleep(50 * time.Millisecond)
+	reads := status()["reads"]
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, reads, status()["reads"], "Should not read a paused sensor")
+
+	// a paused sensor stays paused through a restart
+	assert.Nil(t, res.Reconfigure(context.Background(), deps, conf(50)), "Error should be nil")
+	assert.Equal(t, true, status()["paused"])
+
+	_, err = do("publish_now", "uptime")
+	assert.ErrorIs(t, err, ErrNotConnected, "Should sample a paused sensor on demand")
+	assert.EqualValues(t, 1, status()["reads"])
+
+	_, err = do("resume")
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, false, status()["paused"])
+
+	_, err = do("reconnect", "uptime")
+	assert.Nil(t, err, "Error should be nil")
+
+	_, err = do("pause", "temperature")
+	assert.ErrorIs(t, err, ErrUnknownSensor)
+	_, err = do("explode")
+	assert.ErrorIs(t, err, ErrUnknownCommand)
+}
+
+type fakePublisher struct {
+	conf    goroslib.PublisherConf
+	written []interface{}
+	closed  bool
+}
+
+func (p *fakePublisher) Write(msg interface{}) {
+	p.written = append(p.written, msg)
+}
+
+func (p *fakePublisher) Close() {
+	p.closed = true
+}
+
+// fakePublishers replaces the node and publisher constructors for the test and returns the
+// publishers created
+func fakePublishers(t *testing.T) *[]*fakePublisher {
+	publishers := []*fakePublisher{}
+	oldGetRosNode, oldReleaseNode, oldNewPublisher := getRosNode, releaseNode, newPublisher
+	getRosNode = func(context.Context, logging.Logger, string, string, *utils.RetryConfig, ...viamrosnode.Topic) (*viamrosnode.Handle, error) {
+		return &viamrosnode.Handle{}, nil
+	}
+	releaseNode = func(*viamrosnode.Handle) {}
+	newPublisher = func(node *viamrosnode.Handle, conf goroslib.PublisherConf) (rosPublisher, error) {
+		p := &fakePublisher{conf: conf}
+		publishers = append(publishers, p)
+		return p, nil
+	}
+	t.Cleanup(func() {
+		getRosNode, releaseNode, newPublisher = oldGetRosNode, oldReleaseNode, oldNewPublisher
+	})
+	return &publishers
+}
+
+func TestLatchedReconnect(t *testing.T) {
+	publishers := fakePublishers(t)
+	r := &RosReader{
+		logger:       logging.NewTestLogger(t),
+		sensorConfig: &SensorConfig{Topic: "/mode", Type: "std_msgs/Int32", Name: "mode", Latch: true},
+	}
+	assert.Nil(t, r.connect(context.Background()), "Error should be nil")
+	assert.Len(t, *publishers, 1)
+	assert.True(t, (*publishers)[0].conf.Latch, "Should create a latching publisher")
+	assert.Empty(t, (*publishers)[0].written)
+
+	m := &std_msgs.Int32{Data: 3}
+	r.mu.Lock()
+	r.publish(m)
+	r.mu.Unlock()
+
+	assert.Nil(t, r.connect(context.Background()), "Error should be nil")
+	assert.Len(t, *publishers, 2)
+	assert.True(t, (*publishers)[0].closed, "Should close the old publisher")
+	assert.Equal(t, []interface{}{m}, (*publishers)[1].written, "Should latch the last message again")
+
+	r.sensorConfig.Latch = false
+	r.latched = nil
+	assert.Nil(t, r.connect(context.Background()), "Error should be nil")
+	assert.False(t, (*publishers)[2].conf.Latch)
+	r.mu.Lock()
+	r.publish(m)
+	r.mu.Unlock()
+	assert.Nil(t, r.latched, "Should only keep the message of latched topics")
+}
+
+func TestReconfigureKeepsLatchedMessage(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	cfg, deps := component_test_setup(t)
+	deps[sensor.Named("mode")] = &fakeSensor{Named: sensor.Named("mode").AsNamed()}
+	conf := func(rate float64, typ string) resource.Config {
+		// nothing listens on port 1, so nothing can be published
+		cfg.ConvertedAttributes = &RosBridgeConfig{
+			PrimaryUri:   "127.0.0.1:1",
+			Host:         "127.0.0.1",
+			Sensors:      []*SensorConfig{{Topic: "/mode", Type: typ, Name: "mode", SampleRate: rate, Latch: true}},
+			ConnectRetry: &utils.RetryConfig{InitialDelayMs: 5, MaxDelayMs: 5},
+		}
+		return cfg
+	}
+	res, err := NewRosSensorPublisher(context.Background(), deps, conf(10, "std_msgs/Int32"), logger)
+	assert.Nil(t, err, "Error should be nil")
+	p := res.(*RosSensorPublisher)
+	defer p.Close(context.Background())
+	latched := func() interface{} {
+		reader := p.readers[0]
+		reader.mu.Lock()
+		defer reader.mu.Unlock()
+		return reader.latched
+	}
+
+	m := &std_msgs.Int32{Data: 3}
+	first := p.readers[0]
+	first.mu.Lock()
+	first.latched = m
+	first.mu.Unlock()
+
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, conf(5, "std_msgs/Int32")), "Error should be nil")
+	assert.NotSame(t, first, p.readers[0], "Should restart the changed reader")
+	assert.Same(t, m, latched(), "Should keep the latched message through a restart")
+
+	assert.Nil(t, p.Reconfigure(context.Background(), deps, conf(5, "std_msgs/Int64")), "Error should be nil")
+	assert.Nil(t, latched(), "Should drop the latched message when the type changes")
+}
+
+func TestPublishNowDoesNotBlockReconfigure(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := &RosReader{
+		logger:       logger,
+		sensorConfig: &SensorConfig{Topic: "/uptime", Type: "std_msgs/Int32", Name: "uptime"},
+		done:         make(chan struct{}),
+		now:          make(chan chan error),
+		scheduler:    newScheduler(1),
+	}
+	reader.monitor = utils.NewConnectionMonitor(logger, reader.connect, reader.probe)
+	p := &RosSensorPublisher{logger: logger, readers: []*RosReader{reader}}
+
+	// the reader takes the request but never answers it
+	result := make(chan error, 1)
+	go func() {
+		_, err := p.DoCommand(ctx, map[string]interface{}{"command": "publish_now", "sensor": "uptime"})
+		result <- err
+	}()
+	<-reader.now
+	assert.True(t, p.mu.TryLock(), "Should not hold the lock while waiting for the reader")
+	p.mu.Unlock()
+	assert.Empty(t, result)
+
+	cancel()
+	select {
+	case err := <-result:
+		assert.ErrorIs(t, err, context.Canceled, "Should stop waiting with the context of the caller")
+	case <-time.After(time.Second):
+		t.Fatal("publish_now should return when its context is done")
+	}
+}
@@ -1,11 +1,25 @@
 package ros_sensor_publisher
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/messages"
+	"github.com/viam-soleng/viam-ros-sensor-bridge/utils"
+)
 
 type RosBridgeConfig struct {
-	PrimaryUri string          `json:"primary_uri"`
-	Host       string          `json:"host"`
-	Sensors    []*SensorConfig `json:"sensors"`
+	PrimaryUri         string          `json:"primary_uri"`
+	Host               string          `json:"host"`
+	MessageDefinitions []string        `json:"message_definitions"`
+	Sensors            []*SensorConfig `json:"sensors"`
+	// ConnectRetry is how the node is retried while the master is unreachable
+	ConnectRetry *utils.RetryConfig `json:"connect_retry"`
+
+	// definitionsSum is the checksum of the message definitions, set when they are loaded
+	definitionsSum string
 }
 
 type SensorConfig struct {
@@ -13,13 +27,43 @@ type SensorConfig struct {
 	Type       string  `json:"message_type"`
 	Name       string  `json:"sensor_name"`
 	SampleRate float64 `json:"sample_rate"`
+	// FieldMap maps readings keys to message fields
+	FieldMap *messages.FieldMap `json:"field_map"`
+	// Strict rejects readings that don't match the message exactly instead of dropping fields
+	Strict bool `json:"strict"`
+	// FrameId is set in the header of messages that have one, unless the readings set it
+	FrameId string `json:"frame_id"`
+	// PublishMode is which samples are published: periodic, on_change or deadband
+	PublishMode PublishMode `json:"publish_mode"`
+	// Deadband is how far a numeric field has to move for a sample to be published in deadband mode
+	Deadband float64 `json:"deadband"`
+	// MaxSilenceSecs publishes an unchanged sample when nothing was published for that long
+	MaxSilenceSecs float64 `json:"max_silence_secs"`
+	// Latch sends the last message to subscribers that connect after it was published
+	Latch bool `json:"latch"`
+	// Buffer keeps the messages published while disconnected and replays them on reconnect
+	Buffer *BufferConfig `json:"buffer"`
 }
 
+type PublishMode string
+
+const (
+	// PublishPeriodic publishes every sample, the default
+	PublishPeriodic PublishMode = "periodic"
+	// PublishOnChange publishes samples whose message differs from the last one published
+	PublishOnChange PublishMode = "on_change"
+	// PublishDeadband publishes samples with a numeric field that moved more than the deadband
+	PublishDeadband PublishMode = "deadband"
+)
+
 func (cfg *RosBridgeConfig) Validate(path string) ([]string, error) {
 	// NodeName will get default value if string is empty
 	if cfg.PrimaryUri == "" {
 		return nil, errors.New("primary_uri is required")
 	}
+	if err := cfg.ConnectRetry.Validate(); err != nil {
+		return nil, err
+	}
 
 	if cfg.Sensors == nil {
 		return nil, errors.New("sensors is required")
@@ -35,7 +79,44 @@ func (cfg *RosBridgeConfig) Validate(path string) ([]string, error) {
 		if sensor.Type == "" {
 			return nil, errors.New("sensor type is required")
 		}
+		if sensor.SampleRate < 0 || math.IsInf(sensor.SampleRate, 0) || math.IsNaN(sensor.SampleRate) {
+			return nil, errors.New("sample rate must be a positive number of Hz")
+		}
+		if err := sensor.FieldMap.Validate(); err != nil {
+			return nil, err
+		}
+		switch sensor.PublishMode {
+		case "", PublishPeriodic, PublishOnChange, PublishDeadband:
+		default:
+			return nil, fmt.Errorf("publish mode must be one of %q, %q or %q", PublishPeriodic, PublishOnChange, PublishDeadband)
+		}
+		if sensor.Deadband < 0 || math.IsInf(sensor.Deadband, 0) || math.IsNaN(sensor.Deadband) {
+			return nil, errors.New("deadband must be a positive number")
+		}
+		if sensor.MaxSilenceSecs < 0 || math.IsInf(sensor.MaxSilenceSecs, 0) || math.IsNaN(sensor.MaxSilenceSecs) {
+			return nil, errors.New("max silence must be a positive number of seconds")
+		}
+		if err := sensor.Buffer.Validate(); err != nil {
+			return nil, err
+		}
 	}
 
 	return nil, nil
 }
+
+// sameConnection is false when readers have to be restarted to apply cfg: the master, host,
+// retry settings or message definitions changed, including the contents of the definition files.
+func (cfg *RosBridgeConfig) sameConnection(other *RosBridgeConfig) bool {
+	return cfg.PrimaryUri == other.PrimaryUri &&
+		cfg.Host == other.Host &&
+		reflect.DeepEqual(cfg.ConnectRetry, other.ConnectRetry) &&
+		reflect.DeepEqual(cfg.MessageDefinitions, other.MessageDefinitions) &&
+		cfg.definitionsSum == other.definitionsSum
+}
+
+// restartNeeded is true when the reader of s has to be restarted to apply other, the other
+// settings are updated in place.
+func (s *SensorConfig) restartNeeded(other *SensorConfig) bool {
+	return s.Topic != other.Topic || s.Type != other.Type || s.SampleRate != other.SampleRate || s.Latch != other.Latch ||
+		!reflect.DeepEqual(s.Buffer, other.Buffer)
+}
@@ -0,0 +1,119 @@
+package ros_sensor_publisher
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// statsWindow is the number of ticks the achieved rate and jitter are computed over
+const statsWindow = 100
+
+// scheduler ticks at a fixed rate. Tick n is due n periods after the start, so the time spent
+// handling a tick doesn't delay the following ones. Ticks that are already over by the time the
+// previous one is handled are skipped rather than fired back to back.
+type scheduler struct {
+	rate  float64
+	start time.Time
+	n     int64
+	timer *time.Timer
+	// now is the clock ticks are scheduled with, replaced by tests
+	now func() time.Time
+
+	mu      sync.Mutex
+	ticks   []time.Time
+	skipped int64
+}
+
+// newScheduler returns a scheduler that doesn't tick until begin is called.
+func newScheduler(rate float64) *scheduler {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return &scheduler{
+		rate:  rate,
+		timer: timer,
+		now:   time.Now,
+		ticks: make([]time.Time, 0, statsWindow),
+	}
+}
+
+// begin makes the first tick due right away.
+func (s *scheduler) begin() {
+	s.start = s.now()
+	s.n = 0
+	s.timer.Reset(0)
+}
+
+func (s *scheduler) C() <-chan time.Time {
+	return s.timer.C
+}
+
+// due returns the time tick n is due at.
+func (s *scheduler) due(n int64) time.Time {
+	return s.start.Add(time.Duration(float64(n) * float64(time.Second) / s.rate))
+}
+
+// tick records that a tick is being handled and schedules the next one. It has to be called
+// once for every tick received from C, before handling it.
+func (s *scheduler) tick() {
+	now := s.now()
+	next := s.n + 1
+	if !s.due(next).After(now) {
+		// the tick came late enough that the next ones were missed
+		next = int64(now.Sub(s.start).Seconds()*s.rate) + 1
+		for !s.due(next).After(now) {
+			next++
+		}
+	}
+
+	s.mu.Lock()
+	s.skipped += next - s.n - 1
+	if len(s.ticks) == statsWindow {
+		copy(s.ticks, s.ticks[1:])
+		s.ticks = s.ticks[:statsWindow-1]
+	}
+	s.ticks = append(s.ticks, now)
+	s.mu.Unlock()
+
+	s.n = next
+	s.timer.Reset(s.due(next).Sub(now))
+}
+
+func (s *scheduler) stop() {
+	s.timer.Stop()
+}
+
+// stats returns the target and achieved rates in Hz, the jitter, which is the standard
+// deviation of the time between ticks in milliseconds, and the number of skipped ticks.
+func (s *scheduler) stats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[string]interface{}{
+		"target_hz": s.rate,
+		"rate_hz":   0.0,
+		"jitter_ms": 0.0,
+		"skipped":   s.skipped,
+	}
+	if len(s.ticks) < 2 {
+		return out
+	}
+
+	intervals := make([]float64, len(s.ticks)-1)
+	var mean float64
+	for i := range intervals {
+		intervals[i] = float64(s.ticks[i+1].Sub(s.ticks[i])) / float64(time.Millisecond)
+		mean += intervals[i]
+	}
+	mean /= float64(len(intervals))
+	var variance float64
+	for _, d := range intervals {
+		variance += (d - mean) * (d - mean)
+	}
+	variance /= float64(len(intervals))
+
+	if mean > 0 {
+		out["rate_hz"] = 1000 / mean
+	}
+	out["jitter_ms"] = math.Sqrt(variance)
+	return out
+}
@@ -0,0 +1,70 @@
+package ros_sensor_publisher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerDueTimes(t *testing.T) {
+	s := newScheduler(0.25)
+	s.start = time.Unix(0, 0)
+	assert.Equal(t, time.Unix(4, 0), s.due(1), "Sub-Hz rates should be honored")
+
+	s = newScheduler(60)
+	s.start = time.Unix(0, 0)
+	assert.Equal(t, time.Unix(1, 0), s.due(60), "Due times should not drift")
+	assert.Equal(t, time.Unix(0, 16666666), s.due(1))
+}
+
+// fakeClock is a scheduler clock that only moves when told to.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newFakeScheduler(rate float64) (*scheduler, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	s := newScheduler(rate)
+	s.now = clock.now
+	return s, clock
+}
+
+func TestSchedulerRate(t *testing.T) {
+	s, clock := newFakeScheduler(100)
+	s.begin()
+	defer s.stop()
+	start := clock.t
+	for i := 0; i < 50; i++ {
+		// the timer fires a little late, and readings take most of the period
+		clock.t = s.due(s.n).Add(time.Millisecond)
+		s.tick()
+		clock.t = clock.t.Add(6 * time.Millisecond)
+	}
+	assert.Equal(t, start.Add(500*time.Millisecond), s.due(s.n), "Handling ticks should not slow the rate down")
+
+	stats := s.stats()
+	assert.Equal(t, 100.0, stats["target_hz"])
+	assert.InDelta(t, 100.0, stats["rate_hz"], 1e-9, "Achieved rate should be the target")
+	assert.InDelta(t, 0.0, stats["jitter_ms"], 1e-9)
+	assert.Equal(t, int64(0), stats["skipped"])
+}
+
+func TestSchedulerSkipsMissedTicks(t *testing.T) {
+	s, clock := newFakeScheduler(50)
+	s.begin()
+	defer s.stop()
+	start := clock.t
+	s.tick()
+	clock.t = start.Add(20 * time.Millisecond)
+	s.tick()
+	// handling this tick takes 3.5 periods, the next tick is late and the 2 after it are skipped
+	clock.t = clock.t.Add(70 * time.Millisecond)
+	s.tick()
+	assert.Equal(t, int64(2), s.stats()["skipped"])
+	assert.Equal(t, start.Add(100*time.Millisecond), s.due(s.n), "Ticks should stay on the original schedule")
+}
@@ -2,7 +2,8 @@ package ros_sensor_subscriber
 
 import (
 	"context"
-	"strings"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -14,10 +15,15 @@ import (
 
 	"github.com/viam-soleng/viam-ros-sensor-bridge/messages"
 	"github.com/viam-soleng/viam-ros-sensor-bridge/utils"
+	"github.com/viam-soleng/viam-ros-sensor-bridge/viamrosnode"
 )
 
 var Model = resource.NewModel(utils.Namespace, "ros", "sensor-subscriber")
 
+var ErrUnknownCommand = errors.New("unknown command")
+var ErrUnknownSensor = errors.New("unknown sensor")
+var ErrStale = errors.New("stale readings")
+
 func init() {
 	resource.RegisterComponent(
 		sensor.API,
@@ -32,12 +38,12 @@ func NewRosSensorConsumer(ctx context.Context, deps resource.Dependencies, conf
 	logger.Infof("Starting Ros Sensor Consumer Module %v", utils.Version)
 	c, cancelFunc := context.WithCancel(context.Background())
 	b := RosSensorSubscriber{
-		Named:            conf.ResourceName().AsNamed(),
-		logger:           logger,
-		cancelFunc:       cancelFunc,
-		ctx:              c,
-		requestReconnect: make(chan bool, 100),
+		Named:      conf.ResourceName().AsNamed(),
+		logger:     logger,
+		cancelFunc: cancelFunc,
+		ctx:        c,
 	}
+	b.monitor = utils.NewConnectionMonitor(logger, b.connect, b.probe)
 
 	if err := b.Reconfigure(ctx, deps, conf); err != nil {
 		return nil, err
@@ -47,40 +53,261 @@ func NewRosSensorConsumer(ctx context.Context, deps resource.Dependencies, conf
 
 type RosSensorSubscriber struct {
 	resource.Named
-	mu               sync.RWMutex
-	logger           logging.Logger
-	node             *goroslib.Node
-	cancelFunc       context.CancelFunc
-	ctx              context.Context
-	subscriber       *goroslib.Subscriber
-	lastMessage      map[string]interface{}
-	conf             *RosBridgeConfig
-	requestReconnect chan bool
-}
-
-// Readings implements resource.Sensor.
+	mu         sync.RWMutex
+	logger     logging.Logger
+	cancelFunc context.CancelFunc
+	ctx        context.Context
+	current    *subscription
+	// topics are the topics that got a message, by the key of their sensor
+	topics map[string]*topicState
+	// typeErrs are the errors resolving or checking the message type of topics, by sensor key
+	typeErrs map[string]error
+	conf     *RosBridgeConfig
+	monitor  *utils.ConnectionMonitor
+	// supervisorDone is closed when the supervisor, which runs the connection monitor, has stopped
+	supervisorDone chan struct{}
+}
+
+// topicState is what the component keeps of a topic.
+type topicState struct {
+	// last is the last message, converted for sensor by the subscription it came from
+	last   map[string]interface{}
+	sensor *SensorConfig
+	from   *subscription
+	// received is when last was received
+	received time.Time
+	// history is nil when the sensor doesn't keep one
+	history *history
+}
+
+// subscription is a node and the subscribers of every topic created for one config. Reconnects
+// and reconfigures create a new one and swap it in, so the component never has a half set up
+// subscription.
+type subscription struct {
+	conf *RosBridgeConfig
+	node *viamrosnode.Handle
+	// subscribers are the subscribers of the topics, by sensor key. Only the monitor goroutine
+	// changes them.
+	subscribers map[string]*goroslib.Subscriber
+	// handlers are the message handlers of the topics, by sensor key, changed with the component
+	// lock held
+	handlers map[string]*messages.MessageHandler
+	// unchecked are the registered types of the topics that had no publisher to check the
+	// checksum against yet, by sensor. Only the monitor goroutine uses it.
+	unchecked map[*SensorConfig]string
+	// failed are the errors of the topics whose type couldn't be resolved or didn't match, by
+	// sensor. The probes retry them. Only the monitor goroutine uses it.
+	failed map[*SensorConfig]error
+	// retired is set, with the component lock held, once the subscription is replaced or dropped
+	retired bool
+}
+
+func newSubscription(conf *RosBridgeConfig, node *viamrosnode.Handle) *subscription {
+	return &subscription{
+		conf:        conf,
+		node:        node,
+		subscribers: map[string]*goroslib.Subscriber{},
+		handlers:    map[string]*messages.MessageHandler{},
+		unchecked:   map[*SensorConfig]string{},
+		failed:      map[*SensorConfig]error{},
+	}
+}
+
+func (s *subscription) close() {
+	for _, subscriber := range s.subscribers {
+		subscriber.Close()
+	}
+	if s.node != nil {
+		s.node.Release()
+	}
+}
+
+// Readings implements resource.Sensor. With several sensors, readings map the key of every topic
+// that got a message to its readings, unless extra has the key of one as "sensor". With "since"
+// in extra, the readings of a topic are the messages of its history received since then.
 func (r *RosSensorSubscriber) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	if r.lastMessage == nil {
-		return map[string]interface{}{}, nil
+	sensors, keyed, err := r.selectSensors(extra["sensor"])
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := extra["since"]; ok {
+		since, err := parseTime(extra["since"])
+		if err != nil {
+			return nil, err
+		}
+		return collect(sensors, keyed, r.historyOf(since, time.Time{}, 0, keyed))
+	}
+	now := time.Now()
+	return collect(sensors, keyed, func(s *SensorConfig) (map[string]interface{}, error) {
+		return r.lastReadings(s, now)
+	})
+}
+
+// lastReadings returns the last message of the topic of s with its age in its metadata, and
+// whether it is stale when s has a max age. Without a message, the topic is stale. r.mu must be
+// held.
+func (r *RosSensorSubscriber) lastReadings(s *SensorConfig, now time.Time) (map[string]interface{}, error) {
+	if err := r.typeErrs[s.Key]; err != nil {
+		return nil, err
+	}
+	maxAge := time.Duration(s.MaxAgeSecs * float64(time.Second))
+	state, ok := r.topics[s.Key]
+	if !ok {
+		if maxAge == 0 {
+			return nil, nil
+		}
+		if s.OnStale == StaleFlag {
+			return map[string]interface{}{s.metadataKey(): map[string]interface{}{"stale": true}}, nil
+		}
+		return nil, fmt.Errorf("%w: no message received on %v", ErrStale, s.Topic)
+	}
+
+	age := now.Sub(state.received)
+	stale := maxAge > 0 && age > maxAge
+	if stale && s.OnStale != StaleFlag {
+		return nil, fmt.Errorf("%w: the last message on %v is %v old, max_age_secs is %v", ErrStale, s.Topic, age.Round(time.Millisecond), s.MaxAgeSecs)
+	}
+
+	// the last message is shared by every call, so the maps are copied before adding the age
+	readings := make(map[string]interface{}, len(state.last))
+	for k, v := range state.last {
+		readings[k] = v
+	}
+	metadata := map[string]interface{}{}
+	if m, ok := state.last[s.metadataKey()].(map[string]interface{}); ok {
+		for k, v := range m {
+			metadata[k] = v
+		}
+	}
+	if handler := state.from.handlers[s.Key]; handler != nil {
+		if _, ok := readings[messages.AggregationsKey]; ok {
+			// the window ends now, not at the last message
+			readings[messages.AggregationsKey] = handler.Aggregations(now)
+		}
+	}
+	metadata["age_ms"] = float64(age) / float64(time.Millisecond)
+	if maxAge > 0 {
+		metadata["stale"] = stale
+	}
+	readings[s.metadataKey()] = metadata
+	return readings, nil
+}
+
+// selectSensors returns the sensor of key, or every sensor when key is nil. keyed is true when
+// their readings are returned by key. r.mu must be held.
+func (r *RosSensorSubscriber) selectSensors(key interface{}) ([]*SensorConfig, bool, error) {
+	if r.conf.Sensor != nil {
+		return []*SensorConfig{r.conf.Sensor}, false, nil
+	}
+	if key == nil {
+		return r.conf.Sensors, true, nil
+	}
+	k, _ := key.(string)
+	s := r.conf.sensor(k)
+	if s == nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrUnknownSensor, key)
+	}
+	return []*SensorConfig{s}, false, nil
+}
+
+// collect returns what get returns for the only sensor, or for each sensor by key, leaving out
+// those it returns nil for. By key, the error of a sensor is returned as its "error", so one topic
+// doesn't hide the readings of the others.
+func collect(sensors []*SensorConfig, keyed bool, get func(*SensorConfig) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	if !keyed {
+		m, err := get(sensors[0])
+		if m == nil && err == nil {
+			m = map[string]interface{}{}
+		}
+		return m, err
+	}
+	out := map[string]interface{}{}
+	for _, s := range sensors {
+		m, err := get(s)
+		if err != nil {
+			out[s.Key] = map[string]interface{}{"error": err.Error()}
+			continue
+		}
+		if m != nil {
+			out[s.Key] = m
+		}
+	}
+	return out, nil
+}
+
+// historyOf returns a getter of the history of a topic from start to end, as "messages". Keyed
+// queries leave out the topics without history. r.mu must be held while it is called.
+func (r *RosSensorSubscriber) historyOf(start, end time.Time, limit int, keyed bool) func(*SensorConfig) (map[string]interface{}, error) {
+	now := time.Now()
+	return func(s *SensorConfig) (map[string]interface{}, error) {
+		if s.History == nil {
+			if keyed {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("%w for %v", ErrNoHistory, s.Topic)
+		}
+		messages := []interface{}{}
+		if state, ok := r.topics[s.Key]; ok && state.history != nil {
+			messages = state.history.query(now, start, end, limit)
+		}
+		return map[string]interface{}{"messages": messages}, nil
 	}
-	return r.lastMessage, nil
 }
 
 // Close implements resource.Resource.
 func (r *RosSensorSubscriber) Close(ctx context.Context) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.cancelFunc()
 	r.logger.Info("Closing ROS Sensor Subscriber")
-	r.cleanup()
+	r.cancelFunc()
+	r.mu.RLock()
+	done := r.supervisorDone
+	r.mu.RUnlock()
+	if done != nil {
+		<-done
+	}
+
+	r.mu.Lock()
+	current := r.current
+	r.current = nil
+	if current != nil {
+		current.retired = true
+	}
+	r.mu.Unlock()
+	// closing the subscriber waits for its callback, which takes the lock
+	if current != nil {
+		current.close()
+	}
 	return nil
 }
 
 // DoCommand implements resource.Resource.
-func (*RosSensorSubscriber) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
-	return map[string]interface{}{"ok": 1}, nil
+func (r *RosSensorSubscriber) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["command"] == "connection" {
+		return r.monitor.Status(), nil
+	}
+	if cmd["command"] == "history" {
+		start, err := parseTime(cmd["start"])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseTime(cmd["end"])
+		if err != nil {
+			return nil, err
+		}
+		limit, err := parseLimit(cmd["limit"])
+		if err != nil {
+			return nil, err
+		}
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		sensors, keyed, err := r.selectSensors(cmd["sensor"])
+		if err != nil {
+			return nil, err
+		}
+		return collect(sensors, keyed, r.historyOf(start, end, limit, keyed))
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownCommand, cmd["command"])
 }
 
 // Reconfigure implements resource.Resource.
@@ -94,98 +321,275 @@ func (r *RosSensorSubscriber) Reconfigure(ctx context.Context, deps resource.Dep
 		return err
 	}
 
+	// Load the runtime message types before anything tries to look them up
+	if err := messages.LoadMessageDefinitions(r.logger, newConf.MessageDefinitions); err != nil {
+		return err
+	}
+
 	// In case the module has changed name
 	r.Named = conf.ResourceName().AsNamed()
 	r.conf = newConf
-	viamutils.PanicCapturingGo(r.reconnectHandler())
-	r.requestReconnect <- true
+	r.typeErrs = nil
+	if r.supervisorDone == nil {
+		// the only goroutine of the component, it lives until Close
+		done := make(chan struct{})
+		r.supervisorDone = done
+		viamutils.PanicCapturingGo(func() {
+			defer close(done)
+			r.monitor.Run(r.ctx)
+		})
+	} else {
+		// the supervisor creates a subscription for the new config and swaps it in
+		r.monitor.RequestReconnect("reconfigured")
+	}
 	r.logger.Info("Reconfigured ROS Sensor Subscriber")
 	return nil
 }
 
-func (r *RosSensorSubscriber) reconnectHandler() func() {
-	return func() {
-		lastReconnectRequest := time.Now()
-		for {
-			select {
-			case <-r.ctx.Done():
-				return
-			case val := <-r.requestReconnect:
-				// we want to be able to force a reconfigure
-				if time.Since(lastReconnectRequest) < 1*time.Second && !val {
-					continue
-				}
-				r.logger.Info("Reconnecting to ROS Sensor Subscriber")
-				r.connect()
-				lastReconnectRequest = time.Now()
-			}
+// connect creates a subscription for the current config and swaps it in, then closes the one
+// it replaced. Readings keep returning the last message meanwhile.
+func (r *RosSensorSubscriber) connect(ctx context.Context) error {
+	r.mu.RLock()
+	conf := r.conf
+	r.mu.RUnlock()
+
+	// the topics are claimed, so the new subscribers get another node than the ones they replace
+	sensors := conf.sensors()
+	topics := make([]viamrosnode.Topic, 0, len(sensors))
+	for _, s := range sensors {
+		topics = append(topics, viamrosnode.Topic{Name: s.Topic})
+	}
+	node, err := utils.GetRosNodeWithRetry(ctx, r.logger, conf.PrimaryUri, conf.Host, conf.ConnectRetry, topics...)
+	if err != nil {
+		return err
+	}
+	sub := newSubscription(conf, node)
+
+	for _, s := range sensors {
+		if err := r.subscribe(ctx, sub, s); err != nil {
+			sub.close()
+			return err
 		}
 	}
-}
 
-func (r *RosSensorSubscriber) onLog(level goroslib.LogLevel, msg string) {
-	if level == goroslib.LogLevelFatal {
-		r.logger.Fatal(msg)
-	} else if level == goroslib.LogLevelError {
-		r.logger.Error(msg)
-	} else if level == goroslib.LogLevelWarn {
-		r.logger.Warn(msg)
-	} else if level == goroslib.LogLevelInfo {
-		r.logger.Info(msg)
-	} else if level == goroslib.LogLevelDebug {
-		r.logger.Debug(msg)
+	r.mu.Lock()
+	if ctx.Err() != nil {
+		// closed or reconfigured again while connecting
+		sub.retired = true
+		r.mu.Unlock()
+		sub.close()
+		return ctx.Err()
 	}
+	old := r.swapIn(sub)
+	r.mu.Unlock()
 
-	if strings.Contains(msg, "got an error") && strings.Contains(msg, "tcp") {
-		r.logger.Warn("Got a tcp error, reconnecting")
-		r.requestReconnect <- false
+	// closing the subscribers waits for their callbacks, which take the lock
+	if old != nil {
+		old.close()
 	}
+	r.logger.Infof("Created ROS Subscribers of %v of %v topics", len(sub.subscribers), len(sensors))
+	return nil
 }
 
-func (r *RosSensorSubscriber) connect() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.cleanup()
-	node := utils.GetRosNodeWithRetry(r.logger, r.conf.PrimaryUri, r.conf.Host, r.onLog)
+// swapIn makes sub the current subscription and returns the one it replaces. The topics whose
+// sensor changed start over, the others keep their last message and history. r.mu must be held.
+func (r *RosSensorSubscriber) swapIn(sub *subscription) *subscription {
+	old := r.current
+	r.current = sub
+	r.typeErrs = sub.typeErrors()
+	if old != nil {
+		old.retired = true
+	}
+	sensors := map[string]*SensorConfig{}
+	for _, s := range sub.conf.sensors() {
+		sensors[s.Key] = s
+	}
+	for key, state := range r.topics {
+		if s := sensors[key]; s != nil && state.sensor.sameState(s) {
+			state.sensor = s
+		} else {
+			delete(r.topics, key)
+		}
+	}
+	return old
+}
 
-	r.node = node
+// subscribe creates the subscriber of s in sub. A type that can't be resolved or doesn't match
+// only fails s: its error is kept in sub.failed, for the readings of s and the probes to retry
+// it, and the other topics are subscribed to.
+func (r *RosSensorSubscriber) subscribe(ctx context.Context, sub *subscription, s *SensorConfig) error {
+	typeName, checked, err := resolveType(ctx, sub.conf, sub.node, s)
+	if err != nil {
+		r.logger.Warn(err)
+		sub.failed[s] = err
+		return nil
+	}
+	handler := messages.NewMessageHandler(r.logger, func(m map[string]interface{}) { r.setLastMessage(sub, s, m) }).Aggregate(s.Aggregations)
+	subConf, err := handler.GetSubscriberConfigWithHandler(typeName)
+	if err != nil {
+		err = fmt.Errorf("failed to get subscriber config of %v: %w", s.Topic, err)
+		r.logger.Warn(err)
+		sub.failed[s] = err
+		return nil
+	}
+	subConf.Node = sub.node.Node()
+	subConf.Topic = s.Topic
 
-	r.logger.Infof("Creating ROS Subscriber %v", r.conf.Sensor.Topic)
-	handler := messages.NewMessageHandler(r.logger, r.setLastMessage)
-	conf, err := handler.GetSubscriberConfigWithHandler(r.conf.Sensor.Type)
+	r.logger.Infof("Creating ROS Subscriber %v of %v", s.Topic, typeName)
+	subscriber, err := goroslib.NewSubscriber(*subConf)
+	if err == goroslib.ErrNodeTerminated {
+		// make the next reconnect create a new node
+		sub.node.Discard()
+	}
 	if err != nil {
-		r.logger.Errorf("Failed to get subscriber config: %v", err)
-		return
+		return fmt.Errorf("failed to create subscriber of %v: %w", s.Topic, err)
+	}
+	r.mu.Lock()
+	sub.handlers[s.Key] = handler
+	r.mu.Unlock()
+	sub.subscribers[s.Key] = subscriber
+	delete(sub.failed, s)
+	if !checked {
+		sub.unchecked[s] = typeName
+	}
+	return nil
+}
+
+// unsubscribe closes the subscriber of s in sub, which then failed with err.
+func (r *RosSensorSubscriber) unsubscribe(sub *subscription, s *SensorConfig, err error) {
+	r.logger.Warn(err)
+	if subscriber, ok := sub.subscribers[s.Key]; ok {
+		subscriber.Close()
+		delete(sub.subscribers, s.Key)
 	}
-	conf.Node = r.node
-	conf.Topic = r.conf.Sensor.Topic
+	delete(sub.unchecked, s)
+	sub.failed[s] = err
+}
 
-	subscriber, err := goroslib.NewSubscriber(*conf)
+// probe checks that the master is reachable and still has the subscribers registered. It also
+// retries the topics that failed, and checks the checksum of the topics whose publishers have
+// shown up since they were subscribed to, unsubscribing from them on a mismatch.
+func (r *RosSensorSubscriber) probe() error {
+	r.mu.RLock()
+	current := r.current
+	r.mu.RUnlock()
+	if current == nil {
+		return fmt.Errorf("%w: no subscriber", utils.ErrDegraded)
+	}
+	for _, s := range current.conf.sensors() {
+		if _, failed := current.failed[s]; failed {
+			continue
+		}
+		if err := utils.ProbeTopic(current.node, s.Topic, false); err != nil {
+			return err
+		}
+	}
+	defer r.updateTypeErrors(current)
+
+	for s := range current.failed {
+		if err := r.subscribe(r.ctx, current, s); err != nil {
+			return err
+		}
+	}
+	for s, typeName := range current.unchecked {
+		info, err := utils.GetTopicInfo(r.ctx, current.conf.PrimaryUri, current.node, s.Topic)
+		if err != nil || info.MD5 == "" {
+			continue
+		}
+		if err := messages.CheckMD5(typeName, info.Type, info.MD5); err != nil {
+			r.unsubscribe(current, s, fmt.Errorf("%v published by %v: %w", s.Topic, info.Publisher, err))
+			continue
+		}
+		delete(current.unchecked, s)
+	}
+	return nil
+}
+
+// resolveType returns the registered type of the topic of s, the one the master advertises when
+// s has no message_type, and whether it was checked against the checksum of a publisher. A
+// configured type is trusted until the topic is advertised.
+func resolveType(ctx context.Context, conf *RosBridgeConfig, node *viamrosnode.Handle, s *SensorConfig) (string, bool, error) {
+	info, err := utils.GetTopicInfo(ctx, conf.PrimaryUri, node, s.Topic)
 	if err != nil {
-		r.logger.Errorf("Failed to create subscriber: %v", err)
-		return
+		if s.Type != "" {
+			return s.Type, false, nil
+		}
+		return "", false, fmt.Errorf("failed to detect the message type of %v: %w", s.Topic, err)
 	}
-	r.subscriber = subscriber
-	r.logger.Infof("Created ROS Subscriber %v", r.conf.Sensor.Topic)
+	typeName := s.Type
+	if typeName == "" {
+		typeName, err = messages.ResolveType(info.Type)
+		if err != nil {
+			return "", false, fmt.Errorf("%v advertises %v: %w", s.Topic, info.Type, err)
+		}
+	}
+	if info.MD5 == "" {
+		return typeName, false, nil
+	}
+	if err := messages.CheckMD5(typeName, info.Type, info.MD5); err != nil {
+		return "", false, fmt.Errorf("%v published by %v: %w", s.Topic, info.Publisher, err)
+	}
+	return typeName, true, nil
 }
 
-func (r *RosSensorSubscriber) cleanup() {
-	r.logger.Debug("Stopping existing consumers")
-	if r.subscriber != nil {
-		r.subscriber.Close()
+// typeErrors returns the errors of the failed topics of s by sensor key.
+func (s *subscription) typeErrors() map[string]error {
+	errs := make(map[string]error, len(s.failed))
+	for sensor, err := range s.failed {
+		errs[sensor.Key] = err
 	}
-	r.logger.Debug("Readers stopped")
+	return errs
+}
 
-	if r.node != nil {
-		r.logger.Debug("Closing node")
-		r.node.Close()
+// updateTypeErrors makes readings of the failed topics of sub return their error, while sub is
+// the current subscription.
+func (r *RosSensorSubscriber) updateTypeErrors(sub *subscription) {
+	errs := sub.typeErrors()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == sub {
+		r.typeErrs = errs
 	}
 }
 
-func (r *RosSensorSubscriber) setLastMessage(m map[string]interface{}) {
+// setLastMessage is the message callback of the subscriber of s in sub. Messages of a
+// subscription that has been replaced are dropped, those of one that is about to be swapped in
+// are not.
+func (r *RosSensorSubscriber) setLastMessage(sub *subscription, s *SensorConfig, m map[string]interface{}) {
+	received := time.Now()
+	metadata := map[string]interface{}{"received_ms": received.UnixMilli()}
+	if stamp, ok := messages.HeaderStamp(m); ok {
+		metadata["stamp_ms"] = stamp.UnixMilli()
+		metadata["latency_ms"] = float64(received.Sub(stamp)) / float64(time.Millisecond)
+	}
+
+	m = s.FieldMap.Apply(m)
+	m = s.NonFiniteFloats.Apply(m)
+	// a message field called Timestamp wins, the receive time is in the metadata anyway
+	if _, ok := m["Timestamp"]; !ok && s.timestamp() {
+		m["Timestamp"] = received.UTC().UnixMilli()
+	}
+	m[s.metadataKey()] = metadata
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	m["Timestamp"] = time.Now().UTC().UnixMilli()
-	r.logger.Debugf("Setting last message %v", m)
-	r.lastMessage = m
+	if sub.retired {
+		return
+	}
+	r.logger.Debugf("Setting last message of %v %v", s.Topic, m)
+	if r.topics == nil {
+		r.topics = map[string]*topicState{}
+	}
+	state, ok := r.topics[s.Key]
+	if !ok || !state.sensor.sameState(s) {
+		state = &topicState{}
+		if s.History != nil {
+			state.history = newHistory(s.History)
+		}
+		r.topics[s.Key] = state
+	}
+	state.last, state.sensor, state.from, state.received = m, s, sub, received
+	if state.history != nil {
+		state.history.push(received, m)
+	}
 }
@@ -1,11 +1,20 @@
 package ros_sensor_subscriber
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
 	"go.viam.com/rdk/components/generic"
+	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/messages"
+	"github.com/viam-soleng/viam-ros-sensor-bridge/utils"
 )
 
 func component_test_setup(t *testing.T) (resource.Config, resource.Dependencies) {
@@ -19,3 +28,185 @@ func component_test_setup(t *testing.T) (resource.Config, resource.Dependencies)
 
 	return cfg, resource.Dependencies{}
 }
+
+func TestReconfigureAndCloseDontLeak(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	cfg, deps := component_test_setup(t)
+	conf := func(topic string) *RosBridgeConfig {
+		// nothing listens on port 1, so the subscriber keeps retrying
+		return &RosBridgeConfig{
+			PrimaryUri:   "127.0.0.1:1",
+			Host:         "127.0.0.1",
+			Sensor:       &SensorConfig{Topic: topic, Type: "std_msgs/Int32"},
+			ConnectRetry: &utils.RetryConfig{InitialDelayMs: 5, MaxDelayMs: 5},
+		}
+	}
+	cfg.ConvertedAttributes = conf("/uptime")
+	s, err := NewRosSensorConsumer(context.Background(), deps, cfg, logger)
+	assert.Nil(t, err, "Error should be nil")
+
+	for i := 0; i < 20; i++ {
+		cfg.ConvertedAttributes = conf("/uptime")
+		if i%2 == 0 {
+			cfg.ConvertedAttributes = conf("/other")
+		}
+		start := time.Now()
+		assert.Nil(t, s.Reconfigure(context.Background(), deps, cfg), "Error should be nil")
+		_, err = s.Readings(context.Background(), nil)
+		assert.Nil(t, err, "Error should be nil")
+		assert.Less(t, time.Since(start), 100*time.Millisecond, "Should stay responsive while the master is down")
+	}
+
+	status, err := s.DoCommand(context.Background(), map[string]interface{}{"command": "connection"})
+	assert.Nil(t, err, "Error should be nil")
+	assert.NotEqual(t, "connected", status["state"])
+
+	start := time.Now()
+	assert.Nil(t, s.Close(context.Background()), "Error should be nil")
+	assert.Less(t, time.Since(start), time.Second, "Should close while the master is down")
+}
+
+func TestMultipleSensors(t *testing.T) {
+	conf := &RosBridgeConfig{
+		PrimaryUri: "localhost:11311",
+		Sensors: []*SensorConfig{
+			{Key: "uptime", Topic: "/uptime", Type: "std_msgs/Int32"},
+			{Key: "temperature", Topic: "/temperature", Type: "std_msgs/Float64", MetadataKey: "meta"},
+		},
+	}
+	_, err := conf.Validate("")
+	assert.Nil(t, err, "Error should be nil")
+
+	r := &RosSensorSubscriber{logger: logging.NewTestLogger(t), conf: conf}
+	readings, err := r.Readings(context.Background(), nil)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Empty(t, readings, "Should be empty before the first message")
+
+	sub := &subscription{conf: conf}
+	r.setLastMessage(sub, conf.Sensors[0], map[string]interface{}{"Data": 42})
+	r.setLastMessage(sub, conf.Sensors[1], map[string]interface{}{"Data": 21.5})
+	readings, err = r.Readings(context.Background(), nil)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Len(t, readings, 2)
+	assert.Equal(t, 42, readings["uptime"].(map[string]interface{})["Data"])
+	assert.Contains(t, readings["temperature"], "meta", "Should use the metadata key of the sensor")
+
+	readings, err = r.Readings(context.Background(), map[string]interface{}{"sensor": "temperature"})
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, 21.5, readings["Data"], "Should return the readings of the selected topic")
+	_, err = r.Readings(context.Background(), map[string]interface{}{"sensor": "humidity"})
+	assert.ErrorIs(t, err, ErrUnknownSensor)
+
+	// a stale topic only fails its own readings
+	conf.Sensors[1].MaxAgeSecs = 5
+	r.topics["temperature"].received = time.Now().Add(-time.Minute)
+	readings, err = r.Readings(context.Background(), nil)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, 42, readings["uptime"].(map[string]interface{})["Data"], "Should keep the readings of the other topics")
+	assert.Contains(t, readings["temperature"].(map[string]interface{})["error"], ErrStale.Error())
+	_, err = r.Readings(context.Background(), map[string]interface{}{"sensor": "temperature"})
+	assert.ErrorIs(t, err, ErrStale, "Should fail when the stale topic is selected")
+	conf.Sensors[1].MaxAgeSecs = 0
+
+	for _, invalid := range [][]*SensorConfig{
+		{{Topic: "/uptime", Type: "std_msgs/Int32"}},
+		{{Key: "a", Topic: "/uptime", Type: "std_msgs/Int32"}, {Key: "a", Topic: "/other", Type: "std_msgs/Int32"}},
+		{{Key: "a", Topic: "/uptime", Type: "std_msgs/Int32"}, {Key: "b", Topic: "/uptime", Type: "std_msgs/Int32"}},
+	} {
+		_, err := (&RosBridgeConfig{PrimaryUri: "localhost:11311", Sensors: invalid}).Validate("")
+		assert.NotNil(t, err, "Should reject missing or duplicate keys and topics")
+	}
+	both := &RosBridgeConfig{PrimaryUri: "localhost:11311", Sensor: conf.Sensors[0], Sensors: conf.Sensors}
+	_, err = both.Validate("")
+	assert.NotNil(t, err, "Should reject sensor and sensors together")
+}
+
+func TestStaleReadings(t *testing.T) {
+	sensor := &SensorConfig{Topic: "/uptime", Type: "std_msgs/Int32", MaxAgeSecs: 5}
+	conf := &RosBridgeConfig{PrimaryUri: "localhost:11311", Sensor: sensor}
+	_, err := conf.Validate("")
+	assert.Nil(t, err, "Error should be nil")
+	r := &RosSensorSubscriber{logger: logging.NewTestLogger(t), conf: conf}
+
+	_, err = r.Readings(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrStale, "Should be stale before the first message")
+
+	sub := &subscription{conf: conf}
+	r.setLastMessage(sub, sensor, map[string]interface{}{"Data": 42})
+	readings, err := r.Readings(context.Background(), nil)
+	assert.Nil(t, err, "Error should be nil")
+	metadata := readings["metadata"].(map[string]interface{})
+	assert.Equal(t, false, metadata["stale"])
+	assert.Less(t, metadata["age_ms"], 1000.0)
+	assert.Contains(t, metadata, "received_ms")
+	assert.Equal(t, metadata["received_ms"], readings["Timestamp"], "Should keep the Timestamp of older versions")
+	assert.NotContains(t, r.topics[""].last["metadata"], "age_ms", "Should not modify the last message")
+
+	r.setLastMessage(sub, sensor, map[string]interface{}{"Data": 42, "Timestamp": "from the message"})
+	readings, err = r.Readings(context.Background(), nil)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, "from the message", readings["Timestamp"], "Should keep the Timestamp field of the message")
+	assert.Contains(t, readings["metadata"], "received_ms")
+
+	r.topics[""].received = time.Now().Add(-time.Minute)
+	_, err = r.Readings(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrStale)
+
+	sensor.OnStale = StaleFlag
+	readings, err = r.Readings(context.Background(), nil)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, 42, readings["Data"])
+	metadata = readings["metadata"].(map[string]interface{})
+	assert.Equal(t, true, metadata["stale"])
+	assert.GreaterOrEqual(t, metadata["age_ms"], 60000.0)
+
+	// without a max age, readings only get their age
+	sensor.MaxAgeSecs = 0
+	readings, err = r.Readings(context.Background(), nil)
+	assert.Nil(t, err, "Error should be nil")
+	assert.NotContains(t, readings["metadata"], "stale")
+	assert.Contains(t, readings["metadata"], "age_ms")
+
+	sensor.OnStale = "maybe"
+	_, err = conf.Validate("")
+	assert.NotNil(t, err, "Should reject unknown on_stale modes")
+}
+
+func TestTypeErrors(t *testing.T) {
+	sensor := &SensorConfig{Topic: "/uptime"}
+	conf := &RosBridgeConfig{PrimaryUri: "localhost:11311", Sensor: sensor}
+	_, err := conf.Validate("")
+	assert.Nil(t, err, "Should detect the message type when it is missing")
+	r := &RosSensorSubscriber{logger: logging.NewTestLogger(t), conf: conf}
+
+	sub := newSubscription(conf, nil)
+	r.current = sub
+	r.setLastMessage(sub, sensor, map[string]interface{}{"Data": 42})
+	sub.failed[sensor] = fmt.Errorf("/uptime: %w", messages.ErrChecksumMismatch)
+	r.updateTypeErrors(sub)
+	_, err = r.Readings(context.Background(), nil)
+	assert.ErrorIs(t, err, messages.ErrChecksumMismatch, "Should report the mismatch over the last message")
+
+	delete(sub.failed, sensor)
+	r.updateTypeErrors(sub)
+	readings, err := r.Readings(context.Background(), nil)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, 42, readings["Data"])
+}
+
+func TestQuietAggregations(t *testing.T) {
+	sensor := &SensorConfig{Topic: "/uptime", Type: "std_msgs/Int32", Aggregations: &messages.Aggregations{WindowSecs: 10, Fields: []string{"Data"}, Stats: []messages.Stat{messages.StatCount}}}
+	conf := &RosBridgeConfig{PrimaryUri: "localhost:11311", Sensor: sensor}
+	r := &RosSensorSubscriber{logger: logging.NewTestLogger(t), conf: conf}
+
+	// the handler saw no message in the window, like a topic that went quiet
+	handler := messages.NewMessageHandler(r.logger, nil).Aggregate(sensor.Aggregations)
+	sub := &subscription{conf: conf, handlers: map[string]*messages.MessageHandler{"": handler}}
+	r.setLastMessage(sub, sensor, map[string]interface{}{"Data": 42, messages.AggregationsKey: map[string]interface{}{"Data": map[string]interface{}{"count": 5}}})
+	readings, err := r.Readings(context.Background(), nil)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, map[string]interface{}{"Data": map[string]interface{}{"count": 0}}, readings[messages.AggregationsKey], "Should report the window ending now")
+	assert.Equal(t, 5, r.topics[""].last[messages.AggregationsKey].(map[string]interface{})["Data"].(map[string]interface{})["count"], "Should not modify the last message")
+}
@@ -1,16 +1,81 @@
 package ros_sensor_subscriber
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/messages"
+	"github.com/viam-soleng/viam-ros-sensor-bridge/utils"
+)
 
 type RosBridgeConfig struct {
-	PrimaryUri string        `json:"primary_uri"`
-	Host       string        `json:"host"`
-	Sensor     *SensorConfig `json:"sensor"`
+	PrimaryUri         string        `json:"primary_uri"`
+	Host               string        `json:"host"`
+	MessageDefinitions []string      `json:"message_definitions"`
+	Sensor             *SensorConfig `json:"sensor"`
+	// Sensors subscribes to several topics instead of the single Sensor, readings are keyed by
+	// their Key
+	Sensors []*SensorConfig `json:"sensors"`
+	// ConnectRetry is how the node is retried while the master is unreachable
+	ConnectRetry *utils.RetryConfig `json:"connect_retry"`
 }
 
+// defaultMetadataKey can't clash with message fields, which are capitalized
+const defaultMetadataKey = "metadata"
+
 type SensorConfig struct {
+	// Key is the readings key of the topic in Sensors
+	Key   string `json:"key"`
 	Topic string `json:"topic"`
-	Type  string `json:"message_type"`
+	// Type is detected from the master when empty
+	Type string `json:"message_type"`
+	// FieldMap maps message fields to readings keys
+	FieldMap *messages.FieldMap `json:"field_map"`
+	// NonFiniteFloats is how NaN and ±Inf appear in readings: native, null or string
+	NonFiniteFloats messages.NonFinite `json:"non_finite_floats"`
+	// MetadataKey is the readings key of the receive time, header stamp and latency
+	MetadataKey string `json:"metadata_key"`
+	// Timestamp adds the receive time in milliseconds as a Timestamp key, as older versions did,
+	// unless the message has a field of that name. It is on unless set to false.
+	Timestamp *bool `json:"timestamp"`
+	// History keeps the last messages for the history command and the since option of readings
+	History *HistoryConfig `json:"history"`
+	// Aggregations are statistics of numeric fields over a sliding window, added to the readings
+	Aggregations *messages.Aggregations `json:"aggregations"`
+	// MaxAgeSecs is how old the last message can get before readings are stale, 0 for no limit
+	MaxAgeSecs float64 `json:"max_age_secs"`
+	// OnStale is what readings do once stale: error, the default, or flag
+	OnStale StaleMode `json:"on_stale"`
+}
+
+type StaleMode string
+
+const (
+	// StaleError makes readings return ErrStale
+	StaleError StaleMode = "error"
+	// StaleFlag returns the readings with stale set in their metadata
+	StaleFlag StaleMode = "flag"
+)
+
+func (s *SensorConfig) timestamp() bool {
+	return s.Timestamp == nil || *s.Timestamp
+}
+
+// sameState reports whether the messages kept of a topic for s are still valid for other: only
+// the staleness settings, which apply when reading, differ.
+func (s *SensorConfig) sameState(other *SensorConfig) bool {
+	a := *s
+	a.MaxAgeSecs, a.OnStale = other.MaxAgeSecs, other.OnStale
+	return reflect.DeepEqual(&a, other)
+}
+
+func (s *SensorConfig) metadataKey() string {
+	if s.MetadataKey == "" {
+		return defaultMetadataKey
+	}
+	return s.MetadataKey
 }
 
 func (cfg *RosBridgeConfig) Validate(path string) ([]string, error) {
@@ -18,15 +83,78 @@ func (cfg *RosBridgeConfig) Validate(path string) ([]string, error) {
 	if cfg.PrimaryUri == "" {
 		return nil, errors.New("primary_uri is required")
 	}
-	if cfg.Sensor == nil {
+	if err := cfg.ConnectRetry.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Sensor == nil && len(cfg.Sensors) == 0 {
 		return nil, errors.New("sensor is required")
-	} else {
-		if cfg.Sensor.Topic == "" {
-			return nil, errors.New("topic is required")
+	}
+	if cfg.Sensor != nil && len(cfg.Sensors) > 0 {
+		return nil, errors.New("only one of sensor and sensors can be set")
+	}
+	if cfg.Sensor != nil {
+		return nil, cfg.Sensor.validate()
+	}
+
+	keys := map[string]bool{}
+	topics := map[string]bool{}
+	for _, s := range cfg.Sensors {
+		if s == nil || s.Key == "" {
+			return nil, errors.New("key is required for every sensor")
+		}
+		if keys[s.Key] {
+			return nil, errors.New("sensor keys must be unique")
 		}
-		if cfg.Sensor.Type == "" {
-			return nil, errors.New("sensor type is required")
+		keys[s.Key] = true
+		if err := s.validate(); err != nil {
+			return nil, err
 		}
+		if topics[s.Topic] {
+			return nil, errors.New("sensor topics must be unique")
+		}
+		topics[s.Topic] = true
 	}
 	return nil, nil
 }
+
+func (s *SensorConfig) validate() error {
+	if s.Topic == "" {
+		return errors.New("topic is required")
+	}
+	if err := s.FieldMap.Validate(); err != nil {
+		return err
+	}
+	if err := s.NonFiniteFloats.Validate(); err != nil {
+		return err
+	}
+	if s.MaxAgeSecs < 0 || math.IsInf(s.MaxAgeSecs, 0) || math.IsNaN(s.MaxAgeSecs) {
+		return errors.New("max age must be a positive number of seconds")
+	}
+	switch s.OnStale {
+	case "", StaleError, StaleFlag:
+	default:
+		return fmt.Errorf("on_stale must be %q or %q", StaleError, StaleFlag)
+	}
+	if err := s.History.Validate(); err != nil {
+		return err
+	}
+	return s.Aggregations.Validate()
+}
+
+// sensors returns the sensor, or the sensors, of the config.
+func (cfg *RosBridgeConfig) sensors() []*SensorConfig {
+	if cfg.Sensor != nil {
+		return []*SensorConfig{cfg.Sensor}
+	}
+	return cfg.Sensors
+}
+
+// sensor returns the sensor of key, nil when there is none.
+func (cfg *RosBridgeConfig) sensor(key string) *SensorConfig {
+	for _, s := range cfg.Sensors {
+		if s.Key == key {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package ros_sensor_subscriber
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrNoHistory = errors.New("history is not enabled")
+var ErrInvalidQuery = errors.New("invalid query")
+
+// defaultHistoryMessages caps the histories that only have a max age, so a fast topic can't use
+// up the memory
+const defaultHistoryMessages = 10000
+
+// HistoryConfig is how many of the last messages of a topic are kept.
+type HistoryConfig struct {
+	// MaxMessages is the number of messages kept, 10000 by default
+	MaxMessages int `json:"max_messages"`
+	// MaxAgeSecs drops messages received longer ago than that, 0 for no limit
+	MaxAgeSecs float64 `json:"max_age_secs"`
+}
+
+func (c *HistoryConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxMessages < 0 {
+		return errors.New("history max messages must be a positive number")
+	}
+	if c.MaxAgeSecs < 0 || math.IsInf(c.MaxAgeSecs, 0) || math.IsNaN(c.MaxAgeSecs) {
+		return errors.New("history max age must be a positive number of seconds")
+	}
+	if c.MaxMessages == 0 && c.MaxAgeSecs == 0 {
+		return errors.New("history needs max_messages or max_age_secs")
+	}
+	return nil
+}
+
+type historyEntry struct {
+	at       time.Time
+	readings map[string]interface{}
+}
+
+// history is a ring buffer of the readings of a topic, oldest first. It grows until it holds
+// MaxMessages.
+type history struct {
+	maxMessages int
+	maxAge      time.Duration
+	entries     []historyEntry
+	head        int
+	size        int
+}
+
+func newHistory(conf *HistoryConfig) *history {
+	h := &history{
+		maxMessages: conf.MaxMessages,
+		maxAge:      time.Duration(conf.MaxAgeSecs * float64(time.Second)),
+	}
+	if h.maxMessages == 0 {
+		h.maxMessages = defaultHistoryMessages
+	}
+	return h
+}
+
+// push adds the readings of a message received at at.
+func (h *history) push(at time.Time, readings map[string]interface{}) {
+	h.expire(at)
+	if h.size == h.maxMessages {
+		// overwrite the oldest
+		h.entries[h.head] = historyEntry{at, readings}
+		h.head = (h.head + 1) % len(h.entries)
+		return
+	}
+	if h.size == len(h.entries) {
+		h.grow()
+	}
+	h.entries[(h.head+h.size)%len(h.entries)] = historyEntry{at, readings}
+	h.size++
+}
+
+func (h *history) grow() {
+	capacity := 2 * len(h.entries)
+	if capacity == 0 {
+		capacity = 16
+	}
+	if capacity > h.maxMessages {
+		capacity = h.maxMessages
+	}
+	entries := make([]historyEntry, capacity)
+	for i := 0; i < h.size; i++ {
+		entries[i] = h.get(i)
+	}
+	h.entries, h.head = entries, 0
+}
+
+// get returns the i-th oldest entry.
+func (h *history) get(i int) historyEntry {
+	return h.entries[(h.head+i)%len(h.entries)]
+}
+
+// expire drops the messages older than maxAge at now.
+func (h *history) expire(now time.Time) {
+	if h.maxAge == 0 {
+		return
+	}
+	for h.size > 0 && now.Sub(h.get(0).at) > h.maxAge {
+		h.entries[h.head] = historyEntry{}
+		h.head = (h.head + 1) % len(h.entries)
+		h.size--
+	}
+}
+
+// query returns the readings received from start to end, both included, oldest first. Zero
+// times don't bound the range, a positive limit only returns the last limit messages. Expired
+// messages are skipped, so queries don't modify the history.
+func (h *history) query(now, start, end time.Time, limit int) []interface{} {
+	if h.maxAge > 0 && now.Add(-h.maxAge).After(start) {
+		start = now.Add(-h.maxAge)
+	}
+	out := []interface{}{}
+	for i := 0; i < h.size; i++ {
+		e := h.get(i)
+		if (!start.IsZero() && e.at.Before(start)) || (!end.IsZero() && e.at.After(end)) {
+			continue
+		}
+		out = append(out, e.readings)
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// parseTime parses a time of a query, milliseconds since the Unix epoch or an RFC 3339 string.
+func parseTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		return time.UnixMicro(int64(t * 1000)), nil
+	case int:
+		return time.UnixMilli(int64(t)), nil
+	case int64:
+		return time.UnixMilli(t), nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: time %v is neither milliseconds nor RFC 3339", ErrInvalidQuery, v)
+}
+
+// parseLimit parses the limit of a query, 0 when there is none.
+func parseLimit(v interface{}) (int, error) {
+	switch l := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		if l >= 0 && l == math.Trunc(l) {
+			return int(l), nil
+		}
+	case int:
+		if l >= 0 {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: limit %v must be a positive integer", ErrInvalidQuery, v)
+}
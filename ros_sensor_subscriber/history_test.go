@@ -0,0 +1,142 @@
+package ros_sensor_subscriber
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.viam.com/rdk/logging"
+)
+
+func data(messages []interface{}) []int {
+	out := []int{}
+	for _, m := range messages {
+		out = append(out, m.(map[string]interface{})["Data"].(int))
+	}
+	return out
+}
+
+func TestHistory(t *testing.T) {
+	start := time.Now()
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * time.Second) }
+
+	h := newHistory(&HistoryConfig{MaxMessages: 20})
+	for i := 0; i < 50; i++ {
+		h.push(at(i), map[string]interface{}{"Data": i})
+	}
+	all := data(h.query(at(50), time.Time{}, time.Time{}, 0))
+	assert.Len(t, all, 20, "Should keep max_messages messages")
+	assert.Equal(t, 30, all[0], "Should drop the oldest messages")
+	assert.Equal(t, 49, all[19])
+	assert.Equal(t, []int{35, 36, 37}, data(h.query(at(50), at(35), at(37), 0)), "Should include both ends of the range")
+	assert.Equal(t, []int{36, 37}, data(h.query(at(50), at(35), at(37), 2)), "Should keep the last messages of the limit")
+
+	h = newHistory(&HistoryConfig{MaxAgeSecs: 10})
+	for i := 0; i < 50; i++ {
+		h.push(at(i), map[string]interface{}{"Data": i})
+	}
+	assert.Equal(t, 11, h.size, "Should drop expired messages")
+	assert.Equal(t, []int{45, 46, 47, 48, 49}, data(h.query(at(55), time.Time{}, time.Time{}, 0)), "Should skip expired messages")
+
+	// a max age alone is capped by the default max messages
+	h = newHistory(&HistoryConfig{MaxAgeSecs: 3600})
+	for i := 0; i < defaultHistoryMessages+100; i++ {
+		h.push(start, map[string]interface{}{"Data": i})
+	}
+	assert.Equal(t, defaultHistoryMessages, h.size, "Should cap the history")
+	assert.Len(t, h.entries, defaultHistoryMessages)
+
+	assert.NotNil(t, (&HistoryConfig{}).Validate(), "Should need a limit")
+	assert.NotNil(t, (&HistoryConfig{MaxMessages: -1}).Validate())
+}
+
+func TestParseQuery(t *testing.T) {
+	parsed, err := parseTime(float64(1700000000123))
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, int64(1700000000123), parsed.UnixMilli())
+	parsed, err = parseTime("2023-11-14T22:13:20.123Z")
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, int64(1700000000123), parsed.UnixMilli())
+	_, err = parseTime("yesterday")
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = parseTime(true)
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+
+	limit, err := parseLimit(float64(10))
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, 10, limit)
+	_, err = parseLimit(2.5)
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestHistoryQueries(t *testing.T) {
+	conf := &RosBridgeConfig{
+		PrimaryUri: "localhost:11311",
+		Sensors: []*SensorConfig{
+			{Key: "uptime", Topic: "/uptime", Type: "std_msgs/Int32", History: &HistoryConfig{MaxMessages: 100}},
+			{Key: "temperature", Topic: "/temperature", Type: "std_msgs/Float64"},
+		},
+	}
+	r := &RosSensorSubscriber{logger: logging.NewTestLogger(t), conf: conf}
+	sub := &subscription{conf: conf}
+	before := time.Now()
+	for i := 0; i < 5; i++ {
+		r.setLastMessage(sub, conf.Sensors[0], map[string]interface{}{"Data": i})
+	}
+	r.setLastMessage(sub, conf.Sensors[1], map[string]interface{}{"Data": 21.5})
+
+	out, err := r.DoCommand(context.Background(), map[string]interface{}{"command": "history", "sensor": "uptime", "limit": float64(3)})
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, []int{2, 3, 4}, data(out["messages"].([]interface{})))
+
+	out, err = r.DoCommand(context.Background(), map[string]interface{}{"command": "history", "start": before.Add(time.Hour).Format(time.RFC3339)})
+	assert.Nil(t, err, "Error should be nil")
+	assert.Len(t, out, 1, "Should leave out the topics without history")
+	assert.Empty(t, out["uptime"].(map[string]interface{})["messages"])
+
+	readings, err := r.Readings(context.Background(), map[string]interface{}{"sensor": "uptime", "since": float64(before.UnixMilli())})
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, data(readings["messages"].([]interface{})))
+
+	_, err = r.Readings(context.Background(), map[string]interface{}{"sensor": "temperature", "since": float64(before.UnixMilli())})
+	assert.ErrorIs(t, err, ErrNoHistory)
+	_, err = r.DoCommand(context.Background(), map[string]interface{}{"command": "histories"})
+	assert.ErrorIs(t, err, ErrUnknownCommand)
+	_, err = r.DoCommand(context.Background(), map[string]interface{}{"command": "history", "limit": "all"})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestHistoryKeptThroughReconfigure(t *testing.T) {
+	uptime := func() *SensorConfig {
+		return &SensorConfig{Key: "uptime", Topic: "/uptime", Type: "std_msgs/Int32", History: &HistoryConfig{MaxMessages: 100}}
+	}
+	temperature := func(maxMessages int) *SensorConfig {
+		return &SensorConfig{Key: "temperature", Topic: "/temperature", Type: "std_msgs/Float64", History: &HistoryConfig{MaxMessages: maxMessages}}
+	}
+	conf := &RosBridgeConfig{PrimaryUri: "localhost:11311", Sensors: []*SensorConfig{uptime(), temperature(100)}}
+	r := &RosSensorSubscriber{logger: logging.NewTestLogger(t), conf: conf}
+	sub := newSubscription(conf, nil)
+	r.swapIn(sub)
+	for i := 0; i < 3; i++ {
+		r.setLastMessage(sub, conf.Sensors[0], map[string]interface{}{"Data": i})
+		r.setLastMessage(sub, conf.Sensors[1], map[string]interface{}{"Data": i})
+	}
+
+	// only the history of temperature changes, uptime gets a new but equal config
+	changed := &RosBridgeConfig{PrimaryUri: "localhost:11311", Sensors: []*SensorConfig{uptime(), temperature(10)}}
+	changed.Sensors[0].MaxAgeSecs = 5
+	r.conf = changed
+	next := newSubscription(changed, nil)
+	assert.Same(t, sub, r.swapIn(next))
+
+	out, err := r.DoCommand(context.Background(), map[string]interface{}{"command": "history"})
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, []int{0, 1, 2}, data(out["uptime"].(map[string]interface{})["messages"].([]interface{})), "Should keep the history of unchanged topics")
+	assert.Empty(t, out["temperature"].(map[string]interface{})["messages"], "Should drop the history of changed topics")
+
+	r.setLastMessage(next, changed.Sensors[0], map[string]interface{}{"Data": 3})
+	out, err = r.DoCommand(context.Background(), map[string]interface{}{"command": "history", "sensor": "uptime"})
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, []int{0, 1, 2, 3}, data(out["messages"].([]interface{})))
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes exponentially growing delays between attempts, each randomized by up to
+// Jitter of its value so components that fail together don't retry together.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// Jitter is the fraction, between 0 and 1, a delay is randomly shortened or lengthened by
+	Jitter float64
+
+	attempt int
+}
+
+// NewBackoff returns a backoff starting at 1 second and doubling up to 30 seconds, with 20% jitter.
+func NewBackoff() *Backoff {
+	return &Backoff{
+		Initial:    1 * time.Second,
+		Max:        30 * time.Second,
+		Multiplier: 2,
+		Jitter:     0.2,
+	}
+}
+
+// Next returns the delay before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	d := float64(b.Initial)
+	for i := 0; i < b.attempt && d < float64(b.Max); i++ {
+		d *= b.Multiplier
+	}
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	b.attempt++
+	return time.Duration(d * (1 + b.Jitter*(2*rand.Float64()-1)))
+}
+
+// Reset starts the delays over from Initial.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
@@ -1,37 +1,175 @@
 package utils
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
-	"github.com/bluenviron/goroslib/v2"
 	"go.viam.com/rdk/logging"
 
 	"github.com/viam-soleng/viam-ros-sensor-bridge/viamrosnode"
 )
 
-func GetRosNodeWithRetry(logger logging.Logger, primaryUri string, host string, logCallback func(level goroslib.LogLevel, msg string)) *goroslib.Node {
-	logger.Debug("Creating new node")
+var ErrGaveUp = errors.New("gave up acquiring ROS node")
+var ErrInvalidRetry = errors.New("invalid connect_retry")
+
+// GiveUpError is returned by GetRosNodeWithRetry when it stops trying, because its context is
+// done or it ran out of attempts. It matches ErrGaveUp and the last error of the node.
+type GiveUpError struct {
+	PrimaryUri string
+	Attempts   int
+	// Err is the error of the last attempt, nil when there was none
+	Err error
+	// Cause is the error of the context, nil when the attempts ran out
+	Cause error
+}
+
+func (e *GiveUpError) Error() string {
+	reason := fmt.Sprintf("after %v attempts", e.Attempts)
+	if e.Cause != nil {
+		reason = fmt.Sprintf("%v after %v attempts", e.Cause, e.Attempts)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%v for %v: %v: %v", ErrGaveUp, e.PrimaryUri, reason, e.Err)
+	}
+	return fmt.Sprintf("%v for %v: %v", ErrGaveUp, e.PrimaryUri, reason)
+}
+
+func (e *GiveUpError) Unwrap() []error {
+	errs := []error{ErrGaveUp}
+	if e.Cause != nil {
+		errs = append(errs, e.Cause)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// RetryConfig is how components retry to create their node while the master is unreachable.
+type RetryConfig struct {
+	// InitialDelayMs is the delay after the first failure, it doubles with every other one
+	InitialDelayMs float64 `json:"initial_delay_ms"`
+	// MaxDelayMs caps the delay
+	MaxDelayMs float64 `json:"max_delay_ms"`
+	// MaxAttempts is the number of attempts before giving up, 0 for no limit
+	MaxAttempts int `json:"max_attempts"`
+}
+
+func (c *RetryConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.InitialDelayMs < 0 || c.MaxDelayMs < 0 {
+		return fmt.Errorf("%w: delays must be positive", ErrInvalidRetry)
+	}
+	if c.MaxDelayMs != 0 && c.MaxDelayMs < c.InitialDelayMs {
+		return fmt.Errorf("%w: max_delay_ms must be at least initial_delay_ms", ErrInvalidRetry)
+	}
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("%w: max_attempts must be positive", ErrInvalidRetry)
+	}
+	return nil
+}
+
+// Backoff returns the backoff of the config, the defaults of NewBackoff for unset values.
+func (c *RetryConfig) Backoff() *Backoff {
+	b := NewBackoff()
+	if c == nil {
+		return b
+	}
+	if c.InitialDelayMs > 0 {
+		b.Initial = time.Duration(c.InitialDelayMs * float64(time.Millisecond))
+	}
+	if c.MaxDelayMs > 0 {
+		b.Max = time.Duration(c.MaxDelayMs * float64(time.Millisecond))
+	}
+	if b.Max < b.Initial {
+		b.Max = b.Initial
+	}
+	return b
+}
+
+func (c *RetryConfig) maxAttempts() int {
+	if c == nil {
+		return 0
+	}
+	return c.MaxAttempts
+}
+
+// GetRosNodeWithRetry acquires a handle to a shared node of primaryUri and host for topics,
+// retrying with the backoff of retry until the node can be created. It returns a *GiveUpError once
+// ctx is done or the attempts ran out. The handle must be released when it is no longer needed.
+func GetRosNodeWithRetry(ctx context.Context, logger logging.Logger, primaryUri string, host string, retry *RetryConfig, topics ...viamrosnode.Topic) (*viamrosnode.Handle, error) {
+	logger.Debug("Acquiring node")
 
 	now := time.Now()
-	var node *goroslib.Node
+	backoff := retry.Backoff()
+	maxAttempts := retry.maxAttempts()
 	var err error
-	errCount := 0
+	attempts := 0
 	for {
-		node, err = viamrosnode.GetInsanceWithLogCallback(primaryUri, host, logCallback)
-		if node != nil && err == nil {
-			break
+		if ctx.Err() != nil {
+			return nil, &GiveUpError{PrimaryUri: primaryUri, Attempts: attempts, Err: err, Cause: ctx.Err()}
+		}
+		var node *viamrosnode.Handle
+		node, err = acquire(ctx, primaryUri, host, logger, topics)
+		attempts++
+		if err == nil {
+			logger.Debugf("Node acquired in %v", time.Since(now))
+			return node, nil
+		}
+		if ctx.Err() != nil {
+			return nil, &GiveUpError{PrimaryUri: primaryUri, Attempts: attempts, Cause: ctx.Err()}
 		}
 
 		// If we fail to create the node, we will retry
 		logger.Debugf("Failed to create node: %v", err)
-		errCount++
-		if errCount%10 == 0 {
-			logger.Warnf("Failed to create node after 10 attempts: %v", err)
+		if maxAttempts > 0 && attempts >= maxAttempts {
+			return nil, &GiveUpError{PrimaryUri: primaryUri, Attempts: attempts, Err: err}
+		}
+		if attempts%10 == 0 {
+			logger.Warnf("Failed to create node after %v attempts: %v", attempts, err)
 		}
 
-		time.Sleep(1 * time.Second)
+		timer := time.NewTimer(backoff.Next())
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
 	}
+}
+
+// acquireNode and releaseNode are swapped out by the tests
+var acquireNode = viamrosnode.Acquire
+var releaseNode = (*viamrosnode.Handle).Release
 
-	logger.Debugf("Node created in %v", time.Since(now))
-	return node
+// acquire returns as soon as ctx is done, creating a node can take as long as the master takes to
+// time out. The goroutine creating the node releases it when the caller has given up by then.
+func acquire(ctx context.Context, primaryUri string, host string, logger logging.Logger, topics []viamrosnode.Topic) (*viamrosnode.Handle, error) {
+	type result struct {
+		node *viamrosnode.Handle
+		err  error
+	}
+	// unbuffered, so a result is either received by the caller or released here
+	done := make(chan result)
+	go func() {
+		node, err := acquireNode(primaryUri, host, logger, topics...)
+		select {
+		case done <- result{node, err}:
+		case <-ctx.Done():
+			if node != nil {
+				releaseNode(node)
+			}
+		}
+	}()
+
+	select {
+	case res := <-done:
+		return res.node, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
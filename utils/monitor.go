@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/viamrosnode"
+)
+
+// ErrDegraded is wrapped by probe errors for when the master is reachable but the connection isn't
+// working, eg: the publisher is no longer registered after the master restarted.
+var ErrDegraded = errors.New("degraded")
+
+type ConnectionState string
+
+const (
+	StateConnecting   ConnectionState = "connecting"
+	StateConnected    ConnectionState = "connected"
+	StateDegraded     ConnectionState = "degraded"
+	StateDisconnected ConnectionState = "disconnected"
+)
+
+// historySize is the number of transitions a monitor remembers
+const historySize = 50
+
+// defaultProbeInterval is the time between probes while connected
+const defaultProbeInterval = 5 * time.Second
+
+type Transition struct {
+	From   ConnectionState
+	To     ConnectionState
+	At     time.Time
+	Reason string
+}
+
+// ConnectionMonitor keeps a connection to ROS up. It connects, probes the connection every
+// ProbeInterval, and reconnects with backoff when a probe fails.
+type ConnectionMonitor struct {
+	ProbeInterval time.Duration
+	Backoff       *Backoff
+
+	logger logging.Logger
+	// connect (re)creates the connection, giving up when ctx is done
+	connect func(ctx context.Context) error
+	// probe checks the connection, its error wraps ErrDegraded when the master is still reachable
+	probe func() error
+
+	mu      sync.Mutex
+	state   ConnectionState
+	since   time.Time
+	history []Transition
+	// requested is set by RequestReconnect until the monitor reconnects
+	requested     bool
+	cancelConnect context.CancelFunc
+	wake          chan struct{}
+}
+
+func NewConnectionMonitor(logger logging.Logger, connect func(ctx context.Context) error, probe func() error) *ConnectionMonitor {
+	return &ConnectionMonitor{
+		ProbeInterval: defaultProbeInterval,
+		Backoff:       NewBackoff(),
+		logger:        logger,
+		connect:       connect,
+		probe:         probe,
+		state:         StateConnecting,
+		since:         time.Now(),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Run connects and monitors the connection until ctx is done.
+func (m *ConnectionMonitor) Run(ctx context.Context) {
+	for {
+		connectCtx, cancel := context.WithCancel(ctx)
+		m.mu.Lock()
+		m.requested = false
+		m.cancelConnect = cancel
+		m.mu.Unlock()
+		err := m.connect(connectCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && m.reconnectRequested() {
+			// the connect was interrupted to start over, eg: with a new config
+			continue
+		}
+		if err != nil {
+			m.transition(StateDisconnected, err.Error())
+			if !m.wait(ctx, m.Backoff.Next()) {
+				return
+			}
+			m.transition(StateConnecting, "retrying")
+			continue
+		}
+		m.transition(StateConnected, "connected")
+
+		for {
+			if !m.wait(ctx, m.ProbeInterval) {
+				return
+			}
+			if m.reconnectRequested() {
+				m.transition(StateConnecting, "reconnect requested")
+				break
+			}
+			err := m.probe()
+			if err == nil {
+				m.transition(StateConnected, "probe succeeded")
+				m.Backoff.Reset()
+				continue
+			}
+			if errors.Is(err, ErrDegraded) {
+				m.transition(StateDegraded, err.Error())
+			} else {
+				m.transition(StateDisconnected, err.Error())
+			}
+			if !m.wait(ctx, m.Backoff.Next()) {
+				return
+			}
+			m.transition(StateConnecting, "reconnecting")
+			break
+		}
+	}
+}
+
+// RequestReconnect makes the monitor reconnect right away, eg: after a reconfigure. A connect in
+// progress is cancelled and started over.
+func (m *ConnectionMonitor) RequestReconnect(reason string) {
+	m.mu.Lock()
+	m.requested = true
+	if m.cancelConnect != nil {
+		m.cancelConnect()
+	}
+	m.mu.Unlock()
+	m.transition(StateConnecting, reason)
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *ConnectionMonitor) reconnectRequested() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requested
+}
+
+// wait returns after d or a reconnect request, false when ctx is done first.
+func (m *ConnectionMonitor) wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-m.wake:
+		return true
+	case <-timer.C:
+		return true
+	}
+}
+
+func (m *ConnectionMonitor) transition(to ConnectionState, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == to {
+		return
+	}
+	t := Transition{From: m.state, To: to, At: time.Now(), Reason: reason}
+	if len(m.history) == historySize {
+		copy(m.history, m.history[1:])
+		m.history = m.history[:historySize-1]
+	}
+	m.history = append(m.history, t)
+	m.state = to
+	m.since = t.At
+	if to == StateConnected {
+		m.logger.Infof("Connection %v: %v", to, reason)
+	} else {
+		m.logger.Warnf("Connection %v: %v", to, reason)
+	}
+}
+
+func (m *ConnectionMonitor) State() ConnectionState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// Status returns the state, the time it was entered and the transition history, oldest first,
+// in a form DoCommand can return.
+func (m *ConnectionMonitor) Status() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	history := make([]interface{}, len(m.history))
+	for i, t := range m.history {
+		history[i] = map[string]interface{}{
+			"from":   string(t.From),
+			"to":     string(t.To),
+			"at":     t.At.Format(time.RFC3339Nano),
+			"reason": t.Reason,
+		}
+	}
+	return map[string]interface{}{
+		"state":   string(m.state),
+		"since":   m.since.Format(time.RFC3339Nano),
+		"history": history,
+	}
+}
+
+// ProbeTopic checks that the master of node is reachable and has the node registered as a
+// publisher of topic, or as a subscriber when publisher is false.
+func ProbeTopic(node *viamrosnode.Handle, topic string, publisher bool) error {
+	topics, err := node.Node().MasterGetTopics()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(topic, "/") {
+		topic = "/" + topic
+	}
+	registered := false
+	if info, ok := topics[topic]; ok {
+		if publisher {
+			_, registered = info.Publishers[node.Name()]
+		} else {
+			_, registered = info.Subscribers[node.Name()]
+		}
+	}
+	if !registered {
+		role := "subscriber"
+		if publisher {
+			role = "publisher"
+		}
+		return fmt.Errorf("%w: %v is not registered as a %v of %v", ErrDegraded, node.Name(), role, topic)
+	}
+	return nil
+}
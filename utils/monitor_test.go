@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"go.viam.com/rdk/logging"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/viamrosnode"
+)
+
+func TestBackoff(t *testing.T) {
+	b := &Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2, Jitter: 0.2}
+	for i, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		got := b.Next()
+		assert.InDelta(t, float64(want), float64(got), 0.2*float64(want), "Attempt %v", i)
+	}
+	b.Reset()
+	assert.InDelta(t, float64(100*time.Millisecond), float64(b.Next()), float64(20*time.Millisecond), "Should start over after a reset")
+}
+
+func states(m *ConnectionMonitor) []ConnectionState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []ConnectionState{}
+	for _, t := range m.history {
+		out = append(out, t.To)
+	}
+	return out
+}
+
+func TestConnectionMonitor(t *testing.T) {
+	var mu sync.Mutex
+	connects := 0
+	var probeErr error
+	connect := func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		connects++
+		if connects == 1 {
+			return errors.New("master unreachable")
+		}
+		return nil
+	}
+	probe := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		err := probeErr
+		probeErr = nil
+		return err
+	}
+	setProbeErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		probeErr = err
+	}
+
+	m := NewConnectionMonitor(logging.NewTestLogger(t), connect, probe)
+	m.ProbeInterval = 10 * time.Millisecond
+	m.Backoff = &Backoff{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 2}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool { return m.State() == StateConnected }, time.Second, time.Millisecond)
+	assert.Equal(t, []ConnectionState{StateDisconnected, StateConnecting, StateConnected}, states(m))
+
+	setProbeErr(fmt.Errorf("%w: not registered", ErrDegraded))
+	assert.Eventually(t, func() bool { return len(states(m)) == 6 }, time.Second, time.Millisecond)
+	assert.Equal(t, []ConnectionState{StateDegraded, StateConnecting, StateConnected}, states(m)[3:], "Should reconnect when degraded")
+
+	setProbeErr(errors.New("connection refused"))
+	assert.Eventually(t, func() bool { return len(states(m)) == 9 }, time.Second, time.Millisecond)
+	assert.Equal(t, []ConnectionState{StateDisconnected, StateConnecting, StateConnected}, states(m)[6:], "Should reconnect when disconnected")
+
+	m.RequestReconnect("reconfigured")
+	assert.Eventually(t, func() bool { return len(states(m)) == 11 }, time.Second, time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, 5, connects)
+	mu.Unlock()
+
+	status := m.Status()
+	assert.Equal(t, "connected", status["state"])
+	assert.Len(t, status["history"], 11)
+
+	cancel()
+	<-done
+}
+
+func TestGetRosNodeGivesUp(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	retry := &RetryConfig{InitialDelayMs: 10, MaxDelayMs: 10, MaxAttempts: 2}
+
+	// nothing listens on port 1
+	node, err := GetRosNodeWithRetry(context.Background(), logger, "127.0.0.1:1", "127.0.0.1", retry)
+	assert.Nil(t, node)
+	assert.ErrorIs(t, err, ErrGaveUp)
+	var giveUp *GiveUpError
+	assert.ErrorAs(t, err, &giveUp)
+	assert.Equal(t, 2, giveUp.Attempts, "Should stop after max_attempts")
+	assert.NotNil(t, giveUp.Err, "Should keep the error of the last attempt")
+	assert.Nil(t, giveUp.Cause)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	node, err = GetRosNodeWithRetry(ctx, logger, "127.0.0.1:1", "127.0.0.1", nil)
+	assert.Nil(t, node)
+	assert.ErrorIs(t, err, ErrGaveUp)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "Should give up when the context is done")
+}
+
+func TestGetRosNodeReleasesLateNode(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	logger := logging.NewTestLogger(t)
+
+	// the node is only created after the caller gave up
+	unblock := make(chan struct{})
+	late := &viamrosnode.Handle{}
+	released := make(chan *viamrosnode.Handle, 1)
+	acquireNode = func(string, string, logging.Logger, ...viamrosnode.Topic) (*viamrosnode.Handle, error) {
+		<-unblock
+		return late, nil
+	}
+	releaseNode = func(h *viamrosnode.Handle) { released <- h }
+	t.Cleanup(func() {
+		acquireNode = viamrosnode.Acquire
+		releaseNode = (*viamrosnode.Handle).Release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	node, err := GetRosNodeWithRetry(ctx, logger, "127.0.0.1:1", "127.0.0.1", nil)
+	assert.Nil(t, node)
+	assert.ErrorIs(t, err, ErrGaveUp)
+
+	close(unblock)
+	select {
+	case h := <-released:
+		assert.Same(t, late, h, "Should release the node acquired after giving up")
+	case <-time.After(time.Second):
+		t.Fatal("Should release the node acquired after giving up")
+	}
+}
+
+func TestRetryConfigValidate(t *testing.T) {
+	var nilConfig *RetryConfig
+	assert.Nil(t, nilConfig.Validate())
+	assert.Equal(t, NewBackoff(), nilConfig.Backoff())
+	assert.Nil(t, (&RetryConfig{InitialDelayMs: 100, MaxDelayMs: 1000, MaxAttempts: 5}).Validate())
+	assert.ErrorIs(t, (&RetryConfig{InitialDelayMs: -1}).Validate(), ErrInvalidRetry)
+	assert.ErrorIs(t, (&RetryConfig{InitialDelayMs: 100, MaxDelayMs: 10}).Validate(), ErrInvalidRetry)
+	assert.ErrorIs(t, (&RetryConfig{MaxAttempts: -1}).Validate(), ErrInvalidRetry)
+}
+
+func TestConnectionMonitorRestartsConnect(t *testing.T) {
+	attempts := make(chan struct{}, 10)
+	connect := func(ctx context.Context) error {
+		attempts <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	m := NewConnectionMonitor(logging.NewTestLogger(t), connect, func() error { return nil })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+
+	<-attempts
+	m.RequestReconnect("reconfigured")
+	select {
+	case <-attempts:
+	case <-time.After(time.Second):
+		t.Fatal("Should cancel the connect in progress and start over")
+	}
+	assert.Equal(t, StateConnecting, m.State())
+
+	cancel()
+	<-done
+}
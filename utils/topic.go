@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/apimaster"
+	"github.com/bluenviron/goroslib/v2/pkg/apislave"
+	"github.com/bluenviron/goroslib/v2/pkg/protocommon"
+	"github.com/bluenviron/goroslib/v2/pkg/prototcp"
+
+	"github.com/viam-soleng/viam-ros-sensor-bridge/viamrosnode"
+)
+
+var ErrTopicNotAdvertised = errors.New("topic is not advertised")
+
+// topicInfoTimeout bounds each request to the master and the publishers of a topic
+const topicInfoTimeout = 5 * time.Second
+
+// TopicInfo is what the master and the publishers advertise of a topic.
+type TopicInfo struct {
+	Type string
+	// MD5 is the checksum of the type sent by a publisher, empty when none could be asked
+	MD5 string
+	// Publisher is the node MD5 comes from
+	Publisher string
+}
+
+// GetTopicInfo asks the master of node for the type of topic, and its publishers for the checksum
+// of their definition of it, the way a subscriber connects to them. It fails with
+// ErrTopicNotAdvertised when the master doesn't know the topic. Publishers that can't be reached
+// are skipped, and MD5 is left empty when none of them answers.
+func GetTopicInfo(ctx context.Context, primaryUri string, node *viamrosnode.Handle, topic string) (*TopicInfo, error) {
+	if !strings.HasPrefix(topic, "/") {
+		topic = "/" + topic
+	}
+	topics, err := node.Node().MasterGetTopics()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := topics[topic]
+	if !ok || t.Type == "" {
+		return nil, fmt.Errorf("%w: %v", ErrTopicNotAdvertised, topic)
+	}
+	info := &TopicInfo{Type: t.Type}
+
+	httpClient := &http.Client{Timeout: topicInfoTimeout}
+	master := apimaster.NewClient(primaryUri, node.Name(), httpClient)
+	for publisher := range t.Publishers {
+		if publisher == node.Name() || ctx.Err() != nil {
+			continue
+		}
+		header, err := requestPublisherHeader(ctx, master, httpClient, node.Name(), publisher, topic)
+		if err != nil {
+			continue
+		}
+		info.MD5, info.Publisher = header.Md5sum, publisher
+		break
+	}
+	return info, nil
+}
+
+// requestPublisherHeader connects to publisher as a subscriber of any type and returns the
+// connection header it answers with.
+func requestPublisherHeader(ctx context.Context, master *apimaster.Client, httpClient *http.Client, callerID, publisher, topic string) (*prototcp.HeaderPublisher, error) {
+	nodeURL, err := master.LookupNode(publisher)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(nodeURL)
+	if err != nil {
+		return nil, err
+	}
+	proto, err := apislave.NewClient(u.Host, callerID, httpClient).RequestTopic(topic, [][]interface{}{{"TCPROS"}})
+	if err != nil {
+		return nil, err
+	}
+	if len(proto) != 3 || proto[0] != "TCPROS" {
+		return nil, fmt.Errorf("%v offers no TCPROS connection to %v", publisher, topic)
+	}
+	host, ok := proto[1].(string)
+	port, ok2 := proto[2].(int)
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("%v offers an invalid TCPROS address for %v", publisher, topic)
+	}
+
+	dialer := net.Dialer{Timeout: topicInfoTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(topicInfoTimeout))
+
+	tconn := prototcp.NewConn(conn)
+	err = tconn.WriteHeader(&prototcp.HeaderSubscriber{
+		Callerid:   callerID,
+		Topic:      topic,
+		Type:       "*",
+		Md5sum:     "*",
+		TcpNodelay: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := tconn.ReadHeaderRaw()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := raw["error"]; ok {
+		return nil, fmt.Errorf("%v refused the connection to %v: %v", publisher, topic, e)
+	}
+	var header prototcp.HeaderPublisher
+	if err := protocommon.HeaderDecode(raw, &header); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
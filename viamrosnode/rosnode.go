@@ -6,42 +6,249 @@ import (
 	"sync"
 
 	"github.com/bluenviron/goroslib/v2"
+	"go.viam.com/rdk/logging"
 )
 
 var lock *sync.Mutex = &sync.Mutex{}
 var i int = 0
 
-func getInsance(primary string, nodeConfig goroslib.NodeConf) (*goroslib.Node, error) {
-	node, err := goroslib.NewNode(nodeConfig)
-	if err != nil {
-		return nil, err
+// nodes holds the shared nodes of every (primary, host) pair that has users, oldest first
+var nodes = map[nodeKey][]*sharedNode{}
+
+// newNode and closeNode are swapped out by the tests
+var newNode = goroslib.NewNode
+var closeNode = (*goroslib.Node).Close
+
+type nodeKey struct {
+	primary string
+	host    string
+}
+
+// Topic is a topic a handle publishes or subscribes to. A goroslib node can only publish and
+// subscribe to a topic once, so handles that claim the same topic get different nodes.
+type Topic struct {
+	Name    string
+	Publish bool
+}
+
+func (t Topic) absolute() Topic {
+	if !strings.HasPrefix(t.Name, "/") {
+		t.Name = "/" + t.Name
 	}
+	return t
+}
 
-	return node, nil
+// sharedNode is one goroslib node and the handles using it. The node is closed when the last
+// handle is released.
+type sharedNode struct {
+	key  nodeKey
+	name string
+	node *goroslib.Node
+
+	mu      sync.Mutex
+	handles []*Handle
+	topics  map[Topic]struct{}
+
+	// ready is closed once the node is created, or failed to be with err
+	ready chan struct{}
+	err   error
+}
+
+// Handle is a reference to a shared node. Every publisher and subscriber of the module holds
+// one and releases it instead of closing the node.
+type Handle struct {
+	shared   *sharedNode
+	logger   logging.Logger
+	topics   []Topic
+	released bool
 }
 
-func GetInsanceWithLogCallback(primary string, host string, logCallback func(level goroslib.LogLevel, msg string)) (*goroslib.Node, error) {
+// Acquire returns a handle to a node of primary and host that none of topics are claimed on,
+// creating the node if there is none. Messages the node logs are logged once, with the logger of
+// its oldest handle. The node is created without holding the lock of the package, so a master
+// that doesn't answer only holds up the callers waiting for that node.
+func Acquire(primary string, host string, logger logging.Logger, topics ...Topic) (*Handle, error) {
 	lock.Lock()
-	defer lock.Unlock()
-	defer func() { i = i + 1 }()
-	nodeConfig := goroslib.NodeConf{
-		Name:            strings.Join([]string{"viamrosnode_", primary, strconv.Itoa(i)}, ""),
-		MasterAddress:   primary,
-		Host:            host,
-		LogDestinations: goroslib.LogDestinationCallback,
-		OnLog:           logCallback,
+
+	claims := make([]Topic, len(topics))
+	for j, t := range topics {
+		claims[j] = t.absolute()
+	}
+
+	key := nodeKey{primary: primary, host: host}
+	var shared *sharedNode
+	for _, s := range nodes[key] {
+		if s.free(claims) {
+			shared = s
+			break
+		}
+	}
+	create := shared == nil
+	if create {
+		// the node is listed before it exists, so the topics are claimed on it right away
+		shared = &sharedNode{
+			key:    key,
+			name:   strings.Join([]string{"viamrosnode_", primary, strconv.Itoa(i)}, ""),
+			topics: map[Topic]struct{}{},
+			ready:  make(chan struct{}),
+		}
+		i = i + 1
+		nodes[key] = append(nodes[key], shared)
+	}
+
+	shared.mu.Lock()
+	h := &Handle{shared: shared, logger: logger, topics: claims}
+	shared.handles = append(shared.handles, h)
+	for _, t := range claims {
+		shared.topics[t] = struct{}{}
+	}
+	shared.mu.Unlock()
+	lock.Unlock()
+
+	if create {
+		node, err := newNode(goroslib.NodeConf{
+			Name:            shared.name,
+			MasterAddress:   primary,
+			Host:            host,
+			LogDestinations: goroslib.LogDestinationCallback,
+			OnLog:           shared.log,
+		})
+		lock.Lock()
+		shared.node, shared.err = node, err
+		if err != nil {
+			// the handles of the callers waiting for the node go with it
+			remove(shared)
+		}
+		lock.Unlock()
+		close(shared.ready)
+	}
+
+	<-shared.ready
+	if shared.err != nil {
+		return nil, shared.err
+	}
+	return h, nil
+}
+
+// Node returns the shared node. It must not be closed, release the handle instead.
+func (h *Handle) Node() *goroslib.Node {
+	return h.shared.node
+}
+
+// Name returns the name the shared node is registered with on the master.
+func (h *Handle) Name() string {
+	return "/" + h.shared.name
+}
+
+// Release gives up the handle, closing the node when it was the last one. Releasing a handle
+// twice is a no-op.
+func (h *Handle) Release() {
+	lock.Lock()
+	shared := h.shared
+	shared.mu.Lock()
+	if h.released {
+		shared.mu.Unlock()
+		lock.Unlock()
+		return
+	}
+	h.released = true
+	for j, other := range shared.handles {
+		if other == h {
+			shared.handles = append(shared.handles[:j:j], shared.handles[j+1:]...)
+			break
+		}
+	}
+	for _, t := range h.topics {
+		delete(shared.topics, t)
+	}
+	last := len(shared.handles) == 0
+	shared.mu.Unlock()
+	if last {
+		remove(shared)
+	}
+	lock.Unlock()
+
+	if last {
+		closeNode(shared.node)
 	}
-	return getInsance(primary, nodeConfig)
 }
 
-func GetInstance(primary string, host string) (*goroslib.Node, error) {
+// Discard stops handing out the node of h, eg: after the node terminated. The next Acquire creates
+// a new node, the current one is closed when its last handle is released.
+func (h *Handle) Discard() {
 	lock.Lock()
 	defer lock.Unlock()
-	defer func() { i = i + 1 }()
-	nodeConfig := goroslib.NodeConf{
-		Name:          strings.Join([]string{"viamrosnode_", primary, strconv.Itoa(i)}, ""),
-		MasterAddress: primary,
-		Host:          host,
+	remove(h.shared)
+}
+
+// Users returns the number of handles to the nodes of primary and host, including the ones of
+// callers waiting for their node to be created.
+func Users(primary string, host string) int {
+	lock.Lock()
+	defer lock.Unlock()
+	users := 0
+	for _, shared := range nodes[nodeKey{primary: primary, host: host}] {
+		shared.mu.Lock()
+		users += len(shared.handles)
+		shared.mu.Unlock()
+	}
+	return users
+}
+
+// remove takes shared out of nodes, lock must be held.
+func remove(shared *sharedNode) {
+	list := nodes[shared.key]
+	for j, s := range list {
+		if s == shared {
+			list = append(list[:j:j], list[j+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(nodes, shared.key)
+	} else {
+		nodes[shared.key] = list
+	}
+}
+
+func (s *sharedNode) free(topics []Topic) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		if _, ok := s.topics[t]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// log is the OnLog callback of the node. It only logs the message, so it never holds up goroslib.
+func (s *sharedNode) log(level goroslib.LogLevel, msg string) {
+	s.mu.Lock()
+	var logger logging.Logger
+	if len(s.handles) > 0 {
+		logger = s.handles[0].logger
+	}
+	s.mu.Unlock()
+
+	if logger != nil {
+		logTo(logger, level, msg)
+	}
+}
+
+// logTo writes a message of a goroslib node to logger at the matching level.
+func logTo(logger logging.Logger, level goroslib.LogLevel, msg string) {
+	if level == goroslib.LogLevelFatal {
+		logger.Fatal(msg)
+	} else if level == goroslib.LogLevelError {
+		logger.Error(msg)
+	} else if level == goroslib.LogLevelWarn {
+		logger.Warn(msg)
+	} else if level == goroslib.LogLevelInfo {
+		logger.Info(msg)
+	} else if level == goroslib.LogLevelDebug {
+		logger.Debug(msg)
+	} else {
+		logger.Errorf("Unknown log level: %v, msg: %v", level, msg)
 	}
-	return getInsance(primary, nodeConfig)
 }
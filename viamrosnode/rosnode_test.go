@@ -0,0 +1,169 @@
+package viamrosnode
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2"
+	"github.com/stretchr/testify/assert"
+	"go.viam.com/rdk/logging"
+)
+
+// fakeNodes replaces the goroslib node constructor for the test and returns the number of nodes
+// created and closed, and their configs
+func fakeNodes(t *testing.T) (*int, *int, *[]goroslib.NodeConf) {
+	created, closed := 0, 0
+	confs := []goroslib.NodeConf{}
+	newNode = func(conf goroslib.NodeConf) (*goroslib.Node, error) {
+		created++
+		confs = append(confs, conf)
+		return new(goroslib.Node), nil
+	}
+	closeNode = func(*goroslib.Node) { closed++ }
+	t.Cleanup(func() {
+		newNode = goroslib.NewNode
+		closeNode = (*goroslib.Node).Close
+	})
+	return &created, &closed, &confs
+}
+
+func TestSharedNode(t *testing.T) {
+	created, closed, _ := fakeNodes(t)
+	logger := logging.NewTestLogger(t)
+
+	a, err := Acquire("localhost:11311", "", logger)
+	assert.Nil(t, err, "Error should be nil")
+	b, err := Acquire("localhost:11311", "", logger)
+	assert.Nil(t, err, "Error should be nil")
+	other, err := Acquire("otherhost:11311", "", logger)
+	assert.Nil(t, err, "Error should be nil")
+
+	assert.Equal(t, 2, *created, "Should create one node per primary")
+	assert.Same(t, a.Node(), b.Node(), "Should share the node")
+	assert.NotSame(t, a.Node(), other.Node(), "Should not share the node of another primary")
+	assert.Equal(t, 2, Users("localhost:11311", ""))
+
+	a.Release()
+	a.Release()
+	assert.Equal(t, 0, *closed, "Should keep the node while it has users")
+	assert.Equal(t, 1, Users("localhost:11311", ""))
+
+	b.Release()
+	other.Release()
+	assert.Equal(t, 2, *closed, "Should close the nodes with their last user")
+	assert.Equal(t, 0, Users("localhost:11311", ""))
+
+	c, err := Acquire("localhost:11311", "", logger)
+	assert.Nil(t, err, "Error should be nil")
+	assert.Equal(t, 3, *created, "Should create a new node after the last one closed")
+	c.Release()
+}
+
+func TestDiscardedNode(t *testing.T) {
+	created, closed, _ := fakeNodes(t)
+	logger := logging.NewTestLogger(t)
+
+	a, _ := Acquire("localhost:11311", "", logger)
+	a.Discard()
+	b, _ := Acquire("localhost:11311", "", logger)
+	assert.Equal(t, 2, *created, "Should not hand out a discarded node")
+	assert.NotSame(t, a.Node(), b.Node())
+
+	a.Release()
+	assert.Equal(t, 1, *closed, "Should close the discarded node with its last user")
+	assert.Equal(t, 1, Users("localhost:11311", ""), "Should keep the new node")
+	b.Release()
+	assert.Equal(t, 2, *closed)
+}
+
+func TestSharedNodeTopics(t *testing.T) {
+	created, _, _ := fakeNodes(t)
+	logger := logging.NewTestLogger(t)
+
+	a, _ := Acquire("localhost:11311", "", logger, Topic{Name: "/imu"})
+	b, _ := Acquire("localhost:11311", "", logger, Topic{Name: "/imu", Publish: true}, Topic{Name: "gps"})
+	assert.Same(t, a.Node(), b.Node(), "Should share the node for other topics")
+
+	c, _ := Acquire("localhost:11311", "", logger, Topic{Name: "imu"})
+	assert.Equal(t, 2, *created, "Should not subscribe to a topic twice on a node")
+	assert.NotSame(t, a.Node(), c.Node())
+
+	a.Release()
+	d, _ := Acquire("localhost:11311", "", logger, Topic{Name: "/imu"})
+	assert.Same(t, b.Node(), d.Node(), "Should reuse the node once the topic is released")
+
+	b.Release()
+	c.Release()
+	d.Release()
+	assert.Equal(t, 0, Users("localhost:11311", ""))
+}
+
+func TestSharedNodeLogs(t *testing.T) {
+	_, _, confs := fakeNodes(t)
+	first, firstLogs := logging.NewObservedTestLogger(t)
+	second, secondLogs := logging.NewObservedTestLogger(t)
+
+	a, _ := Acquire("localhost:11311", "", first)
+	b, _ := Acquire("localhost:11311", "", second)
+
+	(*confs)[0].OnLog(goroslib.LogLevelWarn, "got an error")
+	assert.Equal(t, 1, firstLogs.FilterMessage("got an error").Len(), "Should log with the oldest user")
+	assert.Equal(t, 0, secondLogs.Len(), "Should only log once")
+
+	a.Release()
+	(*confs)[0].OnLog(goroslib.LogLevelWarn, "got an error")
+	assert.Equal(t, 1, secondLogs.FilterMessage("got an error").Len(), "Should log with the remaining user")
+	b.Release()
+}
+
+func TestAcquireDoesNotBlockOthers(t *testing.T) {
+	fakeNodes(t)
+	logger := logging.NewTestLogger(t)
+	up, err := Acquire("localhost:11311", "", logger)
+	assert.Nil(t, err, "Error should be nil")
+
+	// the node of a master that is down hangs until unblock is closed
+	unblock := make(chan struct{})
+	started := make(chan struct{})
+	fake := newNode
+	newNode = func(conf goroslib.NodeConf) (*goroslib.Node, error) {
+		if conf.MasterAddress == "down:11311" {
+			close(started)
+			<-unblock
+			return nil, errors.New("master is down")
+		}
+		return fake(conf)
+	}
+	errs := make(chan error, 2)
+	go func() {
+		_, err := Acquire("down:11311", "", logger)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := Acquire("down:11311", "", logger)
+		errs <- err
+	}()
+
+	released := make(chan struct{})
+	go func() {
+		up.Release()
+		other, err := Acquire("otherhost:11311", "", logger)
+		assert.Nil(t, err, "Error should be nil")
+		other.Release()
+		close(released)
+	}()
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("Release should not wait for the node being created")
+	}
+
+	assert.Eventually(t, func() bool { return Users("down:11311", "") == 2 }, time.Second, time.Millisecond, "Should wait for the node being created")
+	close(unblock)
+	for j := 0; j < 2; j++ {
+		assert.NotNil(t, <-errs, "Should return the error of the node to every caller waiting for it")
+	}
+	assert.Equal(t, 0, Users("down:11311", ""), "Should drop the node that failed")
+}